@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"reflect"
 	"testing"
 	"unsafe"
@@ -11,17 +13,100 @@ import (
 // go test -v homework_test.go
 
 func Defragment(memory []byte, pointers []unsafe.Pointer) {
-	if len(memory) == 0 || len(pointers) == 0 {
-		return
+	NewDefragmenter(memory, pointers).DefragmentStep(len(memory))
+}
+
+type DefragmentProgress struct {
+	Moved     int // bytes moved during the last step
+	Compacted int // pointers already placed at the beginning of memory
+	Total     int
+}
+
+func (p DefragmentProgress) Done() bool {
+	return p.Compacted == p.Total
+}
+
+// Defragmenter compacts memory incrementally, so pointers stay valid between steps.
+type Defragmenter struct {
+	memory   []byte
+	pointers []unsafe.Pointer
+	next     int
+}
+
+func NewDefragmenter(memory []byte, pointers []unsafe.Pointer) *Defragmenter {
+	return &Defragmenter{memory: memory, pointers: pointers}
+}
+
+func (d *Defragmenter) DefragmentStep(budgetBytes int) DefragmentProgress {
+	progress := DefragmentProgress{Total: len(d.pointers)}
+	if len(d.memory) == 0 {
+		progress.Compacted = progress.Total
+		return progress
+	}
+	for ; d.next < len(d.pointers); d.next++ {
+		i := d.next
+		if unsafe.Pointer(&d.memory[i]) == d.pointers[i] {
+			continue
+		}
+		if progress.Moved >= budgetBytes {
+			break
+		}
+		idx := int(uintptr(d.pointers[i]) - uintptr(unsafe.Pointer(&d.memory[i])))
+		d.memory[i], d.memory[i+idx] = d.memory[i+idx], d.memory[i]
+		d.pointers[i] = unsafe.Pointer(&d.memory[i])
+		progress.Moved++
 	}
-	for i := range pointers {
-		if unsafe.Pointer(&memory[i]) == pointers[i] {
+	progress.Compacted = d.next
+	return progress
+}
+
+var (
+	ErrPointerOutOfRange = errors.New("pointer is out of memory range")
+	ErrDuplicatePointer  = errors.New("duplicate pointer")
+	ErrOverlappingBlocks = errors.New("overlapping blocks")
+)
+
+// CheckPointers reports every pointer that would make Defragment panic or corrupt memory.
+func CheckPointers(memory []byte, pointers []unsafe.Pointer) error {
+	begin := uintptr(unsafe.Pointer(unsafe.SliceData(memory)))
+	end := begin + uintptr(len(memory))
+	seen := make(map[uintptr]int, len(pointers))
+	var errs []error
+	for i, pointer := range pointers {
+		address := uintptr(pointer)
+		if address < begin || address >= end {
+			errs = append(errs, fmt.Errorf("%w: pointer #%d (%#x) is outside [%#x, %#x)",
+				ErrPointerOutOfRange, i, address, begin, end))
 			continue
 		}
-		idx := int(uintptr(pointers[i]) - uintptr(unsafe.Pointer(&memory[i])))
-		memory[i], memory[i+idx] = memory[i+idx], memory[i]
-		pointers[i] = unsafe.Pointer(&memory[i])
+		offset := int(address - begin)
+		if first, ok := seen[address]; ok {
+			errs = append(errs, fmt.Errorf("%w: pointer #%d and pointer #%d both point to offset %d",
+				ErrDuplicatePointer, first, i, offset))
+			continue
+		}
+		seen[address] = i
+		if offset < i {
+			errs = append(errs, fmt.Errorf("%w: pointer #%d at offset %d overlaps compacted region [0, %d)",
+				ErrOverlappingBlocks, i, offset, i))
+		}
 	}
+	return errors.Join(errs...)
+}
+
+func DefragmentChecked(memory []byte, pointers []unsafe.Pointer) error {
+	if err := CheckPointers(memory, pointers); err != nil {
+		return err
+	}
+	Defragment(memory, pointers)
+	return nil
+}
+
+func NewCheckedDefragmenter(memory []byte, pointers []unsafe.Pointer) (*Defragmenter, error) {
+	if err := CheckPointers(memory, pointers); err != nil {
+		return nil, err
+	}
+	return NewDefragmenter(memory, pointers), nil
 }
 
 func TestDefragmentation(t *testing.T) {
@@ -57,3 +142,120 @@ func TestDefragmentation(t *testing.T) {
 	assert.True(t, reflect.DeepEqual(defragmentedMemory, fragmentedMemory))
 	assert.True(t, reflect.DeepEqual(defragmentedPointers, fragmentedPointers))
 }
+
+func TestDefragmentStep(t *testing.T) {
+	var memory = []byte{
+		0xFF, 0x00, 0x00, 0x00,
+		0x00, 0xFF, 0x00, 0x00,
+		0x00, 0x00, 0xFF, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+	}
+
+	var pointers = []unsafe.Pointer{
+		unsafe.Pointer(&memory[0]),
+		unsafe.Pointer(&memory[5]),
+		unsafe.Pointer(&memory[10]),
+		unsafe.Pointer(&memory[15]),
+	}
+
+	defragmenter := NewDefragmenter(memory, pointers)
+
+	progress := defragmenter.DefragmentStep(2)
+	assert.Equal(t, DefragmentProgress{Moved: 2, Compacted: 3, Total: 4}, progress)
+	assert.False(t, progress.Done())
+	for _, pointer := range pointers {
+		assert.Equal(t, byte(0xFF), *(*byte)(pointer))
+	}
+
+	progress = defragmenter.DefragmentStep(0)
+	assert.Equal(t, DefragmentProgress{Moved: 0, Compacted: 3, Total: 4}, progress)
+
+	progress = defragmenter.DefragmentStep(2)
+	assert.Equal(t, DefragmentProgress{Moved: 1, Compacted: 4, Total: 4}, progress)
+	assert.True(t, progress.Done())
+
+	assert.Equal(t, []byte{
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	}, memory)
+	assert.Equal(t, []unsafe.Pointer{
+		unsafe.Pointer(&memory[0]),
+		unsafe.Pointer(&memory[1]),
+		unsafe.Pointer(&memory[2]),
+		unsafe.Pointer(&memory[3]),
+	}, pointers)
+
+	progress = defragmenter.DefragmentStep(2)
+	assert.Equal(t, DefragmentProgress{Moved: 0, Compacted: 4, Total: 4}, progress)
+}
+
+func TestDefragmentChecked(t *testing.T) {
+	memory := []byte{0x00, 0xFF, 0x00, 0xFF}
+	outside := []byte{0xFF}
+
+	tests := map[string]struct {
+		pointers []unsafe.Pointer
+		errs     []error
+	}{
+		"valid pointers": {
+			pointers: []unsafe.Pointer{
+				unsafe.Pointer(&memory[1]),
+				unsafe.Pointer(&memory[3]),
+			},
+		},
+		"pointer outside memory": {
+			pointers: []unsafe.Pointer{
+				unsafe.Pointer(&memory[1]),
+				unsafe.Pointer(&outside[0]),
+			},
+			errs: []error{ErrPointerOutOfRange},
+		},
+		"duplicate pointers": {
+			pointers: []unsafe.Pointer{
+				unsafe.Pointer(&memory[1]),
+				unsafe.Pointer(&memory[3]),
+				unsafe.Pointer(&memory[3]),
+			},
+			errs: []error{ErrDuplicatePointer},
+		},
+		"overlapping blocks": {
+			pointers: []unsafe.Pointer{
+				unsafe.Pointer(&memory[3]),
+				unsafe.Pointer(&memory[0]),
+			},
+			errs: []error{ErrOverlappingBlocks},
+		},
+		"several violations": {
+			pointers: []unsafe.Pointer{
+				unsafe.Pointer(&memory[2]),
+				unsafe.Pointer(&memory[2]),
+				unsafe.Pointer(&memory[1]),
+				nil,
+			},
+			errs: []error{ErrDuplicatePointer, ErrOverlappingBlocks, ErrPointerOutOfRange},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := CheckPointers(memory, test.pointers)
+			if len(test.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			for _, expected := range test.errs {
+				assert.ErrorIs(t, err, expected)
+			}
+		})
+	}
+
+	_, err := NewCheckedDefragmenter(nil, []unsafe.Pointer{unsafe.Pointer(&memory[0])})
+	assert.ErrorIs(t, err, ErrPointerOutOfRange)
+
+	pointers := []unsafe.Pointer{unsafe.Pointer(&memory[1]), unsafe.Pointer(&memory[3])}
+	assert.NoError(t, DefragmentChecked(memory, pointers))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0x00, 0x00}, memory)
+	assert.Equal(t, []unsafe.Pointer{unsafe.Pointer(&memory[0]), unsafe.Pointer(&memory[1])}, pointers)
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ErrBitFieldOverflow = errors.New("value does not fit into bit field")
+	ErrInvalidBitWidth  = errors.New("invalid bit width")
+)
+
+type BitOrder int
+
+const (
+	// MSBFirst fills every byte from its most significant bit and writes fields high bits first.
+	MSBFirst BitOrder = iota
+	// LSBFirst fills every byte from its least significant bit and writes fields low bits first.
+	LSBFirst
+)
+
+const maxBitWidth = 64
+
+func lowBits(width int) uint64 {
+	if width >= maxBitWidth {
+		return ^uint64(0)
+	}
+	return 1<<width - 1
+}
+
+type BitWriter struct {
+	buf   []byte
+	order BitOrder
+	bits  int
+}
+
+func NewBitWriter(order BitOrder) *BitWriter {
+	return &BitWriter{order: order}
+}
+
+func (w *BitWriter) WriteBits(value uint64, width int) error {
+	if width < 0 || width > maxBitWidth {
+		return fmt.Errorf("%w: %d", ErrInvalidBitWidth, width)
+	}
+	if value&^lowBits(width) != 0 {
+		return fmt.Errorf("%w: %d needs more than %d bits", ErrBitFieldOverflow, value, width)
+	}
+	for width > 0 {
+		index, offset := w.bits/8, w.bits%8
+		if index == len(w.buf) {
+			w.buf = append(w.buf, 0)
+		}
+		n := min(8-offset, width)
+		if w.order == LSBFirst {
+			w.buf[index] |= byte(value&lowBits(n)) << offset
+			value >>= n
+		} else {
+			w.buf[index] |= byte(value>>(width-n)&lowBits(n)) << (8 - offset - n)
+		}
+		width -= n
+		w.bits += n
+	}
+	return nil
+}
+
+func (w *BitWriter) WriteBool(value bool) {
+	bit := uint64(0)
+	if value {
+		bit = 1
+	}
+	_ = w.WriteBits(bit, 1)
+}
+
+// Align pads the current byte with zero bits.
+func (w *BitWriter) Align() {
+	w.bits = len(w.buf) * 8
+}
+
+func (w *BitWriter) Len() int {
+	return w.bits
+}
+
+func (w *BitWriter) Bytes() []byte {
+	return w.buf
+}
+
+type BitReader struct {
+	buf   []byte
+	order BitOrder
+	bits  int
+}
+
+func NewBitReader(buf []byte, order BitOrder) *BitReader {
+	return &BitReader{buf: buf, order: order}
+}
+
+func (r *BitReader) ReadBits(width int) (uint64, error) {
+	if width < 0 || width > maxBitWidth {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidBitWidth, width)
+	}
+	if width > r.Remaining() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	var value uint64
+	for read := 0; read < width; {
+		index, offset := r.bits/8, r.bits%8
+		n := min(8-offset, width-read)
+		if r.order == LSBFirst {
+			chunk := uint64(r.buf[index]>>offset) & lowBits(n)
+			value |= chunk << read
+		} else {
+			chunk := uint64(r.buf[index]>>(8-offset-n)) & lowBits(n)
+			value = value<<n | chunk
+		}
+		read += n
+		r.bits += n
+	}
+	return value, nil
+}
+
+func (r *BitReader) ReadBool() (bool, error) {
+	bit, err := r.ReadBits(1)
+	return bit == 1, err
+}
+
+// Align skips the rest of the current byte.
+func (r *BitReader) Align() {
+	r.bits = (r.bits + 7) / 8 * 8
+}
+
+func (r *BitReader) Remaining() int {
+	return len(r.buf)*8 - r.bits
+}
+
+type BitField struct {
+	Name  string
+	Width int
+}
+
+// BitLayout describes a packed record, fields are stored in declaration order.
+type BitLayout struct {
+	Order  BitOrder
+	Fields []BitField
+}
+
+func (l BitLayout) Pack(values ...uint64) ([]byte, error) {
+	if len(values) != len(l.Fields) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(l.Fields), len(values))
+	}
+	w := NewBitWriter(l.Order)
+	for i, field := range l.Fields {
+		if err := w.WriteBits(values[i], field.Width); err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+	}
+	return w.Bytes(), nil
+}
+
+func (l BitLayout) Unpack(buf []byte) ([]uint64, error) {
+	r := NewBitReader(buf, l.Order)
+	values := make([]uint64, len(l.Fields))
+	for i, field := range l.Fields {
+		value, err := r.ReadBits(field.Width)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func TestBitWriter(t *testing.T) {
+	tests := map[string]struct {
+		order  BitOrder
+		result []byte
+	}{
+		"msb first": {
+			order:  MSBFirst,
+			result: []byte{0b101_11110, 0b000_1_0000, 0b1100_1101, 0b0000_0000},
+		},
+		"lsb first": {
+			order:  LSBFirst,
+			result: []byte{0b10000_101, 0b0000_1_111, 0b1100_1101, 0b0000_0000},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := NewBitWriter(test.order)
+			assert.NoError(t, w.WriteBits(0b101, 3))
+			assert.NoError(t, w.WriteBits(0b11110000, 8))
+			w.WriteBool(true)
+			w.Align()
+			assert.NoError(t, w.WriteBits(0xCD, 8))
+			assert.NoError(t, w.WriteBits(0, 4))
+			assert.Equal(t, 28, w.Len())
+			assert.Equal(t, test.result, w.Bytes())
+
+			r := NewBitReader(w.Bytes(), test.order)
+			value, err := r.ReadBits(3)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(0b101), value)
+			value, err = r.ReadBits(8)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(0b11110000), value)
+			flag, err := r.ReadBool()
+			assert.NoError(t, err)
+			assert.True(t, flag)
+			r.Align()
+			value, err = r.ReadBits(8)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(0xCD), value)
+			assert.Equal(t, 8, r.Remaining())
+			_, err = r.ReadBits(9)
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		})
+	}
+}
+
+func TestBitWriterErrors(t *testing.T) {
+	w := NewBitWriter(MSBFirst)
+	assert.ErrorIs(t, w.WriteBits(16, 4), ErrBitFieldOverflow)
+	assert.ErrorIs(t, w.WriteBits(0, 65), ErrInvalidBitWidth)
+	assert.NoError(t, w.WriteBits(^uint64(0), 64))
+	assert.Equal(t, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, w.Bytes())
+
+	_, err := NewBitReader(nil, LSBFirst).ReadBits(-1)
+	assert.ErrorIs(t, err, ErrInvalidBitWidth)
+}
+
+func TestBitLayoutManaHealth(t *testing.T) {
+	// the same layout as GamePerson.manaHealth: 12 bits of health, then 12 bits of mana
+	manaHealth := BitLayout{
+		Order: LSBFirst,
+		Fields: []BitField{
+			{Name: "health", Width: 12},
+			{Name: "mana", Width: 12},
+		},
+	}
+
+	const health, mana = 1000, 1000
+	packed, err := manaHealth.Pack(health, mana)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xE8, 0x83, 0x3E}, packed)
+
+	values, err := manaHealth.Unpack(packed)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{health, mana}, values)
+
+	_, err = manaHealth.Pack(health, 5000)
+	assert.ErrorIs(t, err, ErrBitFieldOverflow)
+	assert.ErrorContains(t, err, "field mana")
+
+	_, err = manaHealth.Unpack(packed[:2])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/adler32"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ErrChecksumMismatch    = errors.New("checksum mismatch")
+	ErrUnknownChecksumKind = errors.New("unknown checksum kind")
+)
+
+type ChecksumKind int
+
+const (
+	CRC32 ChecksumKind = iota
+	Adler32
+	FNV32a
+	FNV64a
+)
+
+func (k ChecksumKind) String() string {
+	switch k {
+	case CRC32:
+		return "crc32"
+	case Adler32:
+		return "adler32"
+	case FNV32a:
+		return "fnv32a"
+	case FNV64a:
+		return "fnv64a"
+	default:
+		return fmt.Sprintf("ChecksumKind(%d)", int(k))
+	}
+}
+
+func (k ChecksumKind) valid() bool {
+	return k >= CRC32 && k <= FNV64a
+}
+
+func (k ChecksumKind) Size() int {
+	if k == FNV64a {
+		return 8
+	}
+	return 4
+}
+
+func (k ChecksumKind) newHash() hash.Hash {
+	switch k {
+	case Adler32:
+		return adler32.New()
+	case FNV32a:
+		return fnv.New32a()
+	case FNV64a:
+		return fnv.New64a()
+	default:
+		return crc32.NewIEEE()
+	}
+}
+
+func (k ChecksumKind) check() error {
+	if !k.valid() {
+		return fmt.Errorf("%w: %s", ErrUnknownChecksumKind, k)
+	}
+	return nil
+}
+
+// Checksum is an io.Writer that accumulates a digest and writes it in a fixed byte order.
+type Checksum struct {
+	kind  ChecksumKind
+	order ByteOrder
+	hash  hash.Hash
+}
+
+func NewChecksum(kind ChecksumKind, order ByteOrder) (*Checksum, error) {
+	if err := kind.check(); err != nil {
+		return nil, err
+	}
+	return &Checksum{kind: kind, order: order, hash: kind.newHash()}, nil
+}
+
+func (c *Checksum) Write(p []byte) (int, error) {
+	return c.hash.Write(p)
+}
+
+func (c *Checksum) Reset() {
+	c.hash.Reset()
+}
+
+func (c *Checksum) Digest() uint64 {
+	if h, ok := c.hash.(hash.Hash64); ok {
+		return h.Sum64()
+	}
+	return uint64(c.hash.(hash.Hash32).Sum32())
+}
+
+func (c *Checksum) AppendDigest(buf []byte) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, c.kind.Size())...)
+	if c.kind.Size() == 8 {
+		putNumber(buf[start:], c.Digest(), c.order)
+	} else {
+		putNumber(buf[start:], uint32(c.Digest()), c.order)
+	}
+	return buf
+}
+
+func (c *Checksum) WriteDigest(w io.Writer) error {
+	_, err := w.Write(c.AppendDigest(nil))
+	return err
+}
+
+type ChecksumMismatchError struct {
+	Kind     ChecksumKind
+	Expected uint64
+	Actual   uint64
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("%s checksum mismatch: frame has %#x, payload hashes to %#x", e.Kind, e.Expected, e.Actual)
+}
+
+func (e *ChecksumMismatchError) Unwrap() error {
+	return ErrChecksumMismatch
+}
+
+// FrameChecksum appends a digest of the payload to every frame and verifies it on decode.
+type FrameChecksum struct {
+	Kind  ChecksumKind
+	Order ByteOrder
+}
+
+func (f FrameChecksum) Seal(buf, payload []byte) ([]byte, error) {
+	checksum, err := NewChecksum(f.Kind, f.Order)
+	if err != nil {
+		return buf, err
+	}
+	_, _ = checksum.Write(payload)
+	return checksum.AppendDigest(append(buf, payload...)), nil
+}
+
+func (f FrameChecksum) Open(frame []byte) ([]byte, error) {
+	if err := f.Kind.check(); err != nil {
+		return nil, err
+	}
+	size := f.Kind.Size()
+	if len(frame) < size {
+		return nil, fmt.Errorf("%w: %s frame needs at least %d bytes, got %d", ErrShortBuffer, f.Kind, size, len(frame))
+	}
+	payload, digest := frame[:len(frame)-size], frame[len(frame)-size:]
+
+	var expected uint64
+	if size == 8 {
+		expected = getNumber[uint64](digest, f.Order)
+	} else {
+		expected = uint64(getNumber[uint32](digest, f.Order))
+	}
+
+	checksum, _ := NewChecksum(f.Kind, f.Order)
+	_, _ = checksum.Write(payload)
+	if actual := checksum.Digest(); actual != expected {
+		return nil, &ChecksumMismatchError{Kind: f.Kind, Expected: expected, Actual: actual}
+	}
+	return payload, nil
+}
+
+func TestChecksum(t *testing.T) {
+	const payload = "The quick brown fox jumps over the lazy dog"
+
+	tests := map[string]struct {
+		kind   ChecksumKind
+		order  ByteOrder
+		digest []byte
+	}{
+		"crc32 big endian":     {kind: CRC32, order: BigEndian, digest: []byte{0x41, 0x4F, 0xA3, 0x39}},
+		"crc32 little endian":  {kind: CRC32, order: LittleEndian, digest: []byte{0x39, 0xA3, 0x4F, 0x41}},
+		"adler32 big endian":   {kind: Adler32, order: BigEndian, digest: []byte{0x5B, 0xDC, 0x0F, 0xDA}},
+		"fnv32a big endian":    {kind: FNV32a, order: BigEndian, digest: []byte{0x04, 0x8F, 0xFF, 0x90}},
+		"fnv64a little endian": {kind: FNV64a, order: LittleEndian, digest: []byte{0x10, 0x71, 0xE4, 0xE7, 0xF5, 0xB7, 0xF9, 0xF3}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			checksum, err := NewChecksum(test.kind, test.order)
+			assert.NoError(t, err)
+			_, err = io.Copy(checksum, strings.NewReader(payload))
+			assert.NoError(t, err)
+			assert.Equal(t, test.digest, checksum.AppendDigest(nil))
+
+			var out bytes.Buffer
+			assert.NoError(t, checksum.WriteDigest(&out))
+			assert.Equal(t, test.digest, out.Bytes())
+
+			checksum.Reset()
+			_, _ = checksum.Write([]byte(payload[:10]))
+			_, _ = checksum.Write([]byte(payload[10:]))
+			assert.Equal(t, test.digest, checksum.AppendDigest(nil))
+		})
+	}
+}
+
+func TestFrameChecksum(t *testing.T) {
+	payload := []byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x01}
+
+	for _, kind := range []ChecksumKind{CRC32, Adler32, FNV32a, FNV64a} {
+		t.Run(kind.String(), func(t *testing.T) {
+			frame := FrameChecksum{Kind: kind, Order: BigEndian}
+			sealed, err := frame.Seal([]byte{0x7E}, payload)
+			assert.NoError(t, err)
+			assert.Equal(t, 1+len(payload)+kind.Size(), len(sealed))
+
+			opened, err := frame.Open(sealed[1:])
+			assert.NoError(t, err)
+			assert.Equal(t, payload, opened)
+
+			corrupted := bytes.Clone(sealed[1:])
+			corrupted[0] ^= 0xFF
+			_, err = frame.Open(corrupted)
+			assert.ErrorIs(t, err, ErrChecksumMismatch)
+			var mismatch *ChecksumMismatchError
+			if assert.ErrorAs(t, err, &mismatch) {
+				assert.Equal(t, kind, mismatch.Kind)
+				assert.NotEqual(t, mismatch.Expected, mismatch.Actual)
+			}
+
+			_, err = FrameChecksum{Kind: kind, Order: LittleEndian}.Open(sealed[1:])
+			assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+			_, err = frame.Open(sealed[:2])
+			assert.ErrorIs(t, err, ErrShortBuffer)
+		})
+	}
+}
+
+func TestUnknownChecksumKind(t *testing.T) {
+	unknown := ChecksumKind(9)
+	_, err := NewChecksum(unknown, BigEndian)
+	assert.ErrorIs(t, err, ErrUnknownChecksumKind)
+
+	frame := FrameChecksum{Kind: unknown, Order: BigEndian}
+	sealed, err := frame.Seal([]byte{0x7E}, []byte{0x01, 0x02})
+	assert.ErrorIs(t, err, ErrUnknownChecksumKind)
+	assert.Equal(t, []byte{0x7E}, sealed)
+
+	_, err = frame.Open(make([]byte, 8))
+	assert.ErrorIs(t, err, ErrUnknownChecksumKind)
+	assert.EqualError(t, err, "unknown checksum kind: ChecksumKind(9)")
+}
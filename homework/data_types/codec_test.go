@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ErrUnsupportedType  = errors.New("unsupported type")
+	ErrInvalidBinaryTag = errors.New("invalid binary tag")
+	ErrShortBuffer      = errors.New("short buffer")
+)
+
+type binaryField struct {
+	index    int
+	offset   int
+	order    ByteOrder
+	hasOrder bool
+}
+
+type binaryLayout struct {
+	fields []binaryField
+	size   int
+}
+
+var binaryLayouts sync.Map // reflect.Type -> *binaryLayout
+
+func layoutOf(t reflect.Type) (*binaryLayout, error) {
+	if cached, ok := binaryLayouts.Load(t); ok {
+		return cached.(*binaryLayout), nil
+	}
+	layout := &binaryLayout{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("binary")
+		if tag == "-" {
+			continue
+		}
+		bf := binaryField{index: i, offset: layout.size}
+		switch tag {
+		case "":
+		case "le":
+			bf.order, bf.hasOrder = LittleEndian, true
+		case "be":
+			bf.order, bf.hasOrder = BigEndian, true
+		default:
+			return nil, fmt.Errorf("%w: %s.%s has %q", ErrInvalidBinaryTag, t.Name(), field.Name, tag)
+		}
+		size, err := binarySizeOf(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", t.Name(), field.Name, err)
+		}
+		layout.fields = append(layout.fields, bf)
+		layout.size += size
+	}
+	binaryLayouts.Store(t, layout)
+	return layout, nil
+}
+
+func binarySizeOf(t reflect.Type) (int, error) {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int8, reflect.Uint8:
+		return 1, nil
+	case reflect.Int16, reflect.Uint16:
+		return 2, nil
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 4, nil
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 8, nil
+	case reflect.Array:
+		size, err := binarySizeOf(t.Elem())
+		return size * t.Len(), err
+	case reflect.Struct:
+		layout, err := layoutOf(t)
+		if err != nil {
+			return 0, err
+		}
+		return layout.size, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+	}
+}
+
+// BinarySize returns the number of bytes EncodeBinary produces for data.
+func BinarySize(data any) (int, error) {
+	v, err := indirectValue(data)
+	if err != nil {
+		return 0, err
+	}
+	return binarySizeOf(v.Type())
+}
+
+// indirectValue dereferences data down to its value, rejecting nil interfaces and nil pointers.
+func indirectValue(data any) (reflect.Value, error) {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return v, fmt.Errorf("%w: nil value %T", ErrUnsupportedType, data)
+	}
+	return v, nil
+}
+
+func putNumber[T NumberType](buf []byte, number T, order ByteOrder) {
+	if order != hostByteOrder {
+		number = swapBytes(number)
+	}
+	copy(buf, unsafe.Slice((*byte)(unsafe.Pointer(&number)), unsafe.Sizeof(number)))
+}
+
+func getNumber[T NumberType](buf []byte, order ByteOrder) T {
+	var number T
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&number)), unsafe.Sizeof(number)), buf)
+	if order != hostByteOrder {
+		number = swapBytes(number)
+	}
+	return number
+}
+
+func encodeValue(buf []byte, v reflect.Value, order ByteOrder) {
+	switch v.Kind() {
+	case reflect.Bool:
+		buf[0] = 0
+		if v.Bool() {
+			buf[0] = 1
+		}
+	case reflect.Int8:
+		buf[0] = byte(v.Int())
+	case reflect.Uint8:
+		buf[0] = byte(v.Uint())
+	case reflect.Int16:
+		putNumber(buf, int16(v.Int()), order)
+	case reflect.Uint16:
+		putNumber(buf, uint16(v.Uint()), order)
+	case reflect.Int32:
+		putNumber(buf, int32(v.Int()), order)
+	case reflect.Uint32:
+		putNumber(buf, uint32(v.Uint()), order)
+	case reflect.Int64:
+		putNumber(buf, v.Int(), order)
+	case reflect.Uint64:
+		putNumber(buf, v.Uint(), order)
+	case reflect.Float32:
+		putNumber(buf, float32(v.Float()), order)
+	case reflect.Float64:
+		putNumber(buf, v.Float(), order)
+	case reflect.Array:
+		size, _ := binarySizeOf(v.Type().Elem())
+		for i := range v.Len() {
+			encodeValue(buf[i*size:], v.Index(i), order)
+		}
+	case reflect.Struct:
+		layout, _ := layoutOf(v.Type())
+		for _, field := range layout.fields {
+			fieldOrder := order
+			if field.hasOrder {
+				fieldOrder = field.order
+			}
+			encodeValue(buf[field.offset:], v.Field(field.index), fieldOrder)
+		}
+	}
+}
+
+func decodeValue(buf []byte, v reflect.Value, order ByteOrder) {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(buf[0] != 0)
+	case reflect.Int8:
+		v.SetInt(int64(int8(buf[0])))
+	case reflect.Uint8:
+		v.SetUint(uint64(buf[0]))
+	case reflect.Int16:
+		v.SetInt(int64(getNumber[int16](buf, order)))
+	case reflect.Uint16:
+		v.SetUint(uint64(getNumber[uint16](buf, order)))
+	case reflect.Int32:
+		v.SetInt(int64(getNumber[int32](buf, order)))
+	case reflect.Uint32:
+		v.SetUint(uint64(getNumber[uint32](buf, order)))
+	case reflect.Int64:
+		v.SetInt(getNumber[int64](buf, order))
+	case reflect.Uint64:
+		v.SetUint(getNumber[uint64](buf, order))
+	case reflect.Float32:
+		v.SetFloat(float64(getNumber[float32](buf, order)))
+	case reflect.Float64:
+		v.SetFloat(getNumber[float64](buf, order))
+	case reflect.Array:
+		size, _ := binarySizeOf(v.Type().Elem())
+		for i := range v.Len() {
+			decodeValue(buf[i*size:], v.Index(i), order)
+		}
+	case reflect.Struct:
+		layout, _ := layoutOf(v.Type())
+		for _, field := range layout.fields {
+			fieldOrder := order
+			if field.hasOrder {
+				fieldOrder = field.order
+			}
+			decodeValue(buf[field.offset:], v.Field(field.index), fieldOrder)
+		}
+	}
+}
+
+// AppendBinary encodes data into buf without intermediate allocations once buf has enough capacity.
+// Fields without a binary tag inherit the byte order of the enclosing struct, top level is little-endian.
+func AppendBinary(buf []byte, data any) ([]byte, error) {
+	v, err := indirectValue(data)
+	if err != nil {
+		return buf, err
+	}
+	size, err := binarySizeOf(v.Type())
+	if err != nil {
+		return buf, err
+	}
+	start := len(buf)
+	buf = slices.Grow(buf, size)[:start+size]
+	encodeValue(buf[start:], v, LittleEndian)
+	return buf, nil
+}
+
+func EncodeBinary(data any) ([]byte, error) {
+	return AppendBinary(nil, data)
+}
+
+func WriteBinary(w io.Writer, data any) error {
+	buf, err := EncodeBinary(data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+// DecodeBinary fills the struct pointed to by out directly from buf.
+func DecodeBinary(buf []byte, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("%w: expected non-nil pointer, got %T", ErrUnsupportedType, out)
+	}
+	v = v.Elem()
+	size, err := binarySizeOf(v.Type())
+	if err != nil {
+		return err
+	}
+	if len(buf) < size {
+		return fmt.Errorf("%w: need %d bytes, got %d", ErrShortBuffer, size, len(buf))
+	}
+	decodeValue(buf, v, LittleEndian)
+	return nil
+}
+
+func ReadBinary(r io.Reader, out any) error {
+	size, err := BinarySize(out)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	return DecodeBinary(buf, out)
+}
+
+type wirePoint struct {
+	X, Y float32
+}
+
+type wireHeader struct {
+	Magic    uint32    `binary:"be"`
+	Version  uint16    `binary:"le"`
+	Flags    uint8     `binary:"be"`
+	Valid    bool      `binary:"be"`
+	Length   int64     `binary:"be"`
+	Channels [3]uint16 `binary:"le"`
+	Origin   wirePoint `binary:"be"`
+	Skipped  string    `binary:"-"`
+	internal int
+}
+
+func TestEncodeBinary(t *testing.T) {
+	header := wireHeader{
+		Magic:    0xCAFEBABE,
+		Version:  0x0102,
+		Flags:    0x7F,
+		Valid:    true,
+		Length:   -2,
+		Channels: [3]uint16{0x0001, 0x0203, 0xFFFE},
+		Origin:   wirePoint{X: 1, Y: -2},
+		Skipped:  "not encoded",
+		internal: 42,
+	}
+
+	expected := []byte{
+		0xCA, 0xFE, 0xBA, 0xBE,
+		0x02, 0x01,
+		0x7F,
+		0x01,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFE,
+		0x01, 0x00, 0x03, 0x02, 0xFE, 0xFF,
+		0x3F, 0x80, 0x00, 0x00,
+		0xC0, 0x00, 0x00, 0x00,
+	}
+
+	size, err := BinarySize(header)
+	assert.NoError(t, err)
+	assert.Equal(t, len(expected), size)
+
+	encoded, err := EncodeBinary(&header)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, encoded)
+
+	var decoded wireHeader
+	assert.NoError(t, DecodeBinary(encoded, &decoded))
+	header.Skipped, header.internal = "", 0
+	assert.Equal(t, header, decoded)
+
+	var buffer bytes.Buffer
+	assert.NoError(t, WriteBinary(&buffer, header))
+	assert.Equal(t, expected, buffer.Bytes())
+
+	var read wireHeader
+	assert.NoError(t, ReadBinary(&buffer, &read))
+	assert.Equal(t, header, read)
+}
+
+func TestEncodeBinaryErrors(t *testing.T) {
+	type withInt struct {
+		Value int
+	}
+	type withTag struct {
+		Value uint8 `binary:"middle"`
+	}
+
+	tests := map[string]struct {
+		data any
+		err  error
+	}{
+		"platform dependent int": {data: withInt{}, err: ErrUnsupportedType},
+		"invalid tag":            {data: withTag{}, err: ErrInvalidBinaryTag},
+		"slice":                  {data: []uint8{1}, err: ErrUnsupportedType},
+		"nil pointer":            {data: (*wireHeader)(nil), err: ErrUnsupportedType},
+		"nil interface":          {data: nil, err: ErrUnsupportedType},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := EncodeBinary(test.data)
+			assert.ErrorIs(t, err, test.err)
+			_, err = BinarySize(test.data)
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+
+	var header wireHeader
+	assert.ErrorIs(t, DecodeBinary([]byte{0x01}, &header), ErrShortBuffer)
+	assert.ErrorIs(t, DecodeBinary(make([]byte, 64), header), ErrUnsupportedType)
+}
+
+type benchmarkHeader struct {
+	Magic    uint32    `binary:"be"`
+	Version  uint16    `binary:"be"`
+	Length   uint64    `binary:"be"`
+	Channels [8]uint16 `binary:"be"`
+	Origin   wirePoint `binary:"be"`
+}
+
+var benchmarkHeaderValue = benchmarkHeader{
+	Magic:    0xCAFEBABE,
+	Version:  3,
+	Length:   1 << 40,
+	Channels: [8]uint16{1, 2, 3, 4, 5, 6, 7, 8},
+	Origin:   wirePoint{X: 1.5, Y: -0.5},
+}
+
+func BenchmarkAppendBinary(b *testing.B) {
+	buf := make([]byte, 0, 64)
+	for b.Loop() {
+		buf, _ = AppendBinary(buf[:0], &benchmarkHeaderValue)
+	}
+}
+
+func BenchmarkEncodingBinaryAppend(b *testing.B) {
+	buf := make([]byte, 0, 64)
+	for b.Loop() {
+		buf, _ = binary.Append(buf[:0], binary.BigEndian, &benchmarkHeaderValue)
+	}
+}
+
+func BenchmarkDecodeBinary(b *testing.B) {
+	buf, _ := EncodeBinary(&benchmarkHeaderValue)
+	var header benchmarkHeader
+	for b.Loop() {
+		_ = DecodeBinary(buf, &header)
+	}
+}
+
+func BenchmarkEncodingBinaryDecode(b *testing.B) {
+	buf, _ := EncodeBinary(&benchmarkHeaderValue)
+	var header benchmarkHeader
+	for b.Loop() {
+		_, _ = binary.Decode(buf, binary.BigEndian, &header)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"math"
 	"testing"
 	"unsafe"
 
@@ -10,18 +11,98 @@ import (
 // go test -v homework_test.go
 
 type UintType interface {
-	uint16 | uint32 | uint64
+	uint8 | uint16 | uint32 | uint64 | uint | uintptr
 }
 
-func ToLittleEndian[T UintType](number T) T {
-	const OneByte = 8
-	result := T(0)
-	for length := int8(unsafe.Sizeof(number)); length > 0; length-- {
-		result <<= OneByte
-		result |= number & T(0xFF)
-		number >>= OneByte
+type IntType interface {
+	int8 | int16 | int32 | int64 | int
+}
+
+type FloatType interface {
+	float32 | float64
+}
+
+type NumberType interface {
+	UintType | IntType | FloatType
+}
+
+type ByteOrder int
+
+const (
+	LittleEndian ByteOrder = iota
+	BigEndian
+)
+
+func (o ByteOrder) String() string {
+	if o == BigEndian {
+		return "BigEndian"
+	}
+	return "LittleEndian"
+}
+
+var hostByteOrder = detectHostByteOrder()
+
+func detectHostByteOrder() ByteOrder {
+	probe := uint16(0x0001)
+	if *(*byte)(unsafe.Pointer(&probe)) == 0x01 {
+		return LittleEndian
+	}
+	return BigEndian
+}
+
+func HostByteOrder() ByteOrder {
+	return hostByteOrder
+}
+
+// swapBytes reverses the in-memory representation, so floats are swapped by their bit patterns.
+func swapBytes[T NumberType](number T) T {
+	bytes := unsafe.Slice((*byte)(unsafe.Pointer(&number)), unsafe.Sizeof(number))
+	for i, j := 0, len(bytes)-1; i < j; i, j = i+1, j-1 {
+		bytes[i], bytes[j] = bytes[j], bytes[i]
+	}
+	return number
+}
+
+// ToLittleEndian and ToBigEndian always reverse the byte order regardless of the host, they are
+// kept for compatibility. HostToLittleEndian and HostToBigEndian respect the host order.
+func ToLittleEndian[T NumberType](number T) T {
+	return swapBytes(number)
+}
+
+// ToBigEndian always reverses the byte order regardless of the host, see ToLittleEndian.
+func ToBigEndian[T NumberType](number T) T {
+	return swapBytes(number)
+}
+
+// HostToLittleEndian converts a host-order number to little-endian, a no-op on little-endian hosts.
+func HostToLittleEndian[T NumberType](number T) T {
+	if hostByteOrder == LittleEndian {
+		return number
+	}
+	return swapBytes(number)
+}
+
+// HostToBigEndian converts a host-order number to big-endian, a no-op on big-endian hosts.
+func HostToBigEndian[T NumberType](number T) T {
+	if hostByteOrder == BigEndian {
+		return number
+	}
+	return swapBytes(number)
+}
+
+func HostToNetwork[T NumberType](number T) T {
+	return HostToBigEndian(number)
+}
+
+func NetworkToHost[T NumberType](number T) T {
+	return HostToNetwork(number)
+}
+
+// ConvertEach applies conversion to every element in place, e.g. ConvertEach(array[:], HostToBigEndian[uint32]).
+func ConvertEach[T NumberType](numbers []T, convert func(T) T) {
+	for i := range numbers {
+		numbers[i] = convert(numbers[i])
 	}
-	return result
 }
 
 func TestConversion(t *testing.T) {
@@ -128,3 +209,84 @@ func TestConversionUint16(t *testing.T) {
 		})
 	}
 }
+
+func TestConversionSigned(t *testing.T) {
+	assert.Equal(t, int8(-2), ToLittleEndian(int8(-2)))
+	assert.Equal(t, int16(0x3412), ToLittleEndian(int16(0x1234)))
+	assert.Equal(t, int16(-1), ToLittleEndian(int16(-1)))
+	assert.Equal(t, int32(0x04030201), ToLittleEndian(int32(0x01020304)))
+	assert.Equal(t, int32(math.MinInt32), ToLittleEndian(int32(0x00000080)))
+	assert.Equal(t, int64(0x0807060504030201), ToLittleEndian(int64(0x0102030405060708)))
+	assert.Equal(t, uint8(0xAB), ToLittleEndian(uint8(0xAB)))
+}
+
+func TestConversionFloat(t *testing.T) {
+	tests := map[string]struct {
+		number float64
+	}{
+		"zero":     {number: 0},
+		"pi":       {number: math.Pi},
+		"negative": {number: -12345.6789},
+		"infinity": {number: math.Inf(1)},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result := ToLittleEndian(test.number)
+			assert.Equal(t, ToLittleEndian(math.Float64bits(test.number)), math.Float64bits(result))
+			assert.Equal(t, test.number, ToLittleEndian(result))
+
+			number32 := float32(test.number)
+			result32 := ToLittleEndian(number32)
+			assert.Equal(t, ToLittleEndian(math.Float32bits(number32)), math.Float32bits(result32))
+		})
+	}
+}
+
+func TestHostToNetwork(t *testing.T) {
+	var probe = [2]byte{0x01, 0x00}
+	expectedOrder := BigEndian
+	if *(*uint16)(unsafe.Pointer(&probe)) == 0x0001 {
+		expectedOrder = LittleEndian
+	}
+	assert.Equal(t, expectedOrder, HostByteOrder())
+
+	number := uint32(0x01020304)
+	network := HostToNetwork(number)
+	bytes := *(*[4]byte)(unsafe.Pointer(&network))
+	assert.Equal(t, [4]byte{0x01, 0x02, 0x03, 0x04}, bytes)
+	assert.Equal(t, number, NetworkToHost(network))
+
+	if HostByteOrder() == BigEndian {
+		assert.Equal(t, number, network)
+	}
+}
+
+func TestHostConversion(t *testing.T) {
+	number := uint32(0x01020304)
+	big := HostToBigEndian(number)
+	little := HostToLittleEndian(number)
+	assert.Equal(t, [4]byte{0x01, 0x02, 0x03, 0x04}, *(*[4]byte)(unsafe.Pointer(&big)))
+	assert.Equal(t, [4]byte{0x04, 0x03, 0x02, 0x01}, *(*[4]byte)(unsafe.Pointer(&little)))
+
+	if HostByteOrder() == BigEndian {
+		assert.Equal(t, number, big)
+		assert.Equal(t, uint32(0x04030201), little)
+	} else {
+		assert.Equal(t, number, little)
+		assert.Equal(t, uint32(0x04030201), big)
+	}
+	assert.Equal(t, 1.5, HostToLittleEndian(HostToLittleEndian(1.5)))
+	assert.Equal(t, ToLittleEndian(number), ToBigEndian(number))
+}
+
+func TestConvertEach(t *testing.T) {
+	array := [3]uint16{0x0102, 0x0304, 0x0506}
+	ConvertEach(array[:], ToLittleEndian[uint16])
+	assert.Equal(t, [3]uint16{0x0201, 0x0403, 0x0605}, array)
+
+	floats := [2]float32{1.5, -2.25}
+	ConvertEach(floats[:], HostToNetwork[float32])
+	ConvertEach(floats[:], NetworkToHost[float32])
+	assert.Equal(t, [2]float32{1.5, -2.25}, floats)
+}
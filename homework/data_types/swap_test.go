@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ErrInvalidWidth = errors.New("invalid word width")
+
+const (
+	evenBytesMask = 0x00FF00FF00FF00FF
+	evenWordsMask = 0x0000FFFF0000FFFF
+)
+
+// SwapSlice reverses the byte order of every element in place.
+func SwapSlice[T UintType](numbers []T) {
+	if len(numbers) == 0 {
+		return
+	}
+	var zero T
+	width := int(unsafe.Sizeof(zero))
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(numbers))), len(numbers)*width)
+	_ = SwapBytesInPlace(buf, width)
+}
+
+// SwapBytesInPlace reverses every width-byte word of buf, eight bytes per iteration.
+func SwapBytesInPlace(buf []byte, width int) error {
+	if width != 1 && width != 2 && width != 4 && width != 8 {
+		return fmt.Errorf("%w: %d", ErrInvalidWidth, width)
+	}
+	if len(buf)%width != 0 {
+		return fmt.Errorf("%w: buffer length %d is not a multiple of %d", ErrInvalidWidth, len(buf), width)
+	}
+	if width == 1 {
+		return nil
+	}
+
+	i := 0
+	for ; i+8 <= len(buf); i += 8 {
+		word := binary.LittleEndian.Uint64(buf[i:])
+		switch width {
+		case 2:
+			word = (word&evenBytesMask)<<8 | (word>>8)&evenBytesMask
+		case 4:
+			word = (word&evenBytesMask)<<8 | (word>>8)&evenBytesMask
+			word = (word&evenWordsMask)<<16 | (word>>16)&evenWordsMask
+		case 8:
+			word = bits.ReverseBytes64(word)
+		}
+		binary.LittleEndian.PutUint64(buf[i:], word)
+	}
+	for ; i < len(buf); i += width {
+		for l, r := i, i+width-1; l < r; l, r = l+1, r-1 {
+			buf[l], buf[r] = buf[r], buf[l]
+		}
+	}
+	return nil
+}
+
+func swapScalar[T UintType](numbers []T) {
+	for i := range numbers {
+		numbers[i] = ToLittleEndian(numbers[i])
+	}
+}
+
+func randomSlice[T UintType](length int) []T {
+	numbers := make([]T, length)
+	for i := range numbers {
+		numbers[i] = T(rand.Uint64())
+	}
+	return numbers
+}
+
+func testSwapSlice[T UintType](t *testing.T) {
+	for _, length := range []int{0, 1, 2, 3, 5, 8, 13, 64, 1001} {
+		numbers := randomSlice[T](length)
+		expected := slices.Clone(numbers)
+		swapScalar(expected)
+		SwapSlice(numbers)
+		assert.Equal(t, expected, numbers, "length %d", length)
+	}
+}
+
+func TestSwapSlice(t *testing.T) {
+	t.Run("uint8", testSwapSlice[uint8])
+	t.Run("uint16", testSwapSlice[uint16])
+	t.Run("uint32", testSwapSlice[uint32])
+	t.Run("uint64", testSwapSlice[uint64])
+	t.Run("uintptr", testSwapSlice[uintptr])
+}
+
+func TestSwapBytesInPlace(t *testing.T) {
+	tests := map[string]struct {
+		buf    []byte
+		width  int
+		result []byte
+		err    error
+	}{
+		"width 2 with tail": {
+			buf:    []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+			width:  2,
+			result: []byte{2, 1, 4, 3, 6, 5, 8, 7, 10, 9},
+		},
+		"width 4 with tail": {
+			buf:    []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+			width:  4,
+			result: []byte{4, 3, 2, 1, 8, 7, 6, 5, 12, 11, 10, 9},
+		},
+		"width 8": {
+			buf:    []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			width:  8,
+			result: []byte{8, 7, 6, 5, 4, 3, 2, 1},
+		},
+		"width 1": {
+			buf:    []byte{1, 2, 3},
+			width:  1,
+			result: []byte{1, 2, 3},
+		},
+		"unsupported width": {
+			buf:    []byte{1, 2, 3},
+			width:  3,
+			result: []byte{1, 2, 3},
+			err:    ErrInvalidWidth,
+		},
+		"partial word": {
+			buf:    []byte{1, 2, 3},
+			width:  2,
+			result: []byte{1, 2, 3},
+			err:    ErrInvalidWidth,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := SwapBytesInPlace(test.buf, test.width)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.result, test.buf)
+		})
+	}
+}
+
+const benchmarkFrameSize = 4 << 20
+
+func benchmarkSwap[T UintType](b *testing.B, swap func([]T)) {
+	var zero T
+	numbers := randomSlice[T](benchmarkFrameSize / int(unsafe.Sizeof(zero)))
+	b.SetBytes(benchmarkFrameSize)
+	for b.Loop() {
+		swap(numbers)
+	}
+}
+
+func BenchmarkSwapSlice(b *testing.B) {
+	b.Run("uint16/bulk", func(b *testing.B) { benchmarkSwap(b, SwapSlice[uint16]) })
+	b.Run("uint16/scalar", func(b *testing.B) { benchmarkSwap(b, swapScalar[uint16]) })
+	b.Run("uint32/bulk", func(b *testing.B) { benchmarkSwap(b, SwapSlice[uint32]) })
+	b.Run("uint32/scalar", func(b *testing.B) { benchmarkSwap(b, swapScalar[uint32]) })
+	b.Run("uint64/bulk", func(b *testing.B) { benchmarkSwap(b, SwapSlice[uint64]) })
+	b.Run("uint64/scalar", func(b *testing.B) { benchmarkSwap(b, swapScalar[uint64]) })
+}
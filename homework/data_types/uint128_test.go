@@ -0,0 +1,387 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand/v2"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Uint128 keeps its halves in host order, so its memory layout matches a native
+// 128-bit integer. It stays out of NumberType to keep homework_test.go self-contained
+// and has its own byte-order methods instead.
+type Uint128 [2]uint64
+
+var uint128Lo, uint128Hi = uint128Halves()
+
+func uint128Halves() (int, int) {
+	if hostByteOrder == LittleEndian {
+		return 0, 1
+	}
+	return 1, 0
+}
+
+func NewUint128(hi, lo uint64) Uint128 {
+	var u Uint128
+	u[uint128Hi], u[uint128Lo] = hi, lo
+	return u
+}
+
+func Uint128From64(number uint64) Uint128 {
+	return NewUint128(0, number)
+}
+
+// Uint128FromBytes interprets b as a big-endian number, the way UUIDs and IPv6 addresses are stored.
+func Uint128FromBytes(b [16]byte) Uint128 {
+	return (*(*Uint128)(unsafe.Pointer(&b))).NetworkToHost()
+}
+
+func (u Uint128) Bytes() [16]byte {
+	network := u.HostToNetwork()
+	return *(*[16]byte)(unsafe.Pointer(&network))
+}
+
+// ReverseBytes reverses the in-memory representation like ToLittleEndian does for NumberType.
+func (u Uint128) ReverseBytes() Uint128 {
+	return Uint128{bits.ReverseBytes64(u[1]), bits.ReverseBytes64(u[0])}
+}
+
+func (u Uint128) HostToNetwork() Uint128 {
+	if hostByteOrder == BigEndian {
+		return u
+	}
+	return u.ReverseBytes()
+}
+
+func (u Uint128) NetworkToHost() Uint128 {
+	return u.HostToNetwork()
+}
+
+func (u Uint128) Hi() uint64 {
+	return u[uint128Hi]
+}
+
+func (u Uint128) Lo() uint64 {
+	return u[uint128Lo]
+}
+
+func (u Uint128) IsZero() bool {
+	return u.Hi() == 0 && u.Lo() == 0
+}
+
+func (u Uint128) Cmp(v Uint128) int {
+	switch {
+	case u.Hi() < v.Hi(), u.Hi() == v.Hi() && u.Lo() < v.Lo():
+		return -1
+	case u == v:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func (u Uint128) Add(v Uint128) Uint128 {
+	lo, carry := bits.Add64(u.Lo(), v.Lo(), 0)
+	hi, _ := bits.Add64(u.Hi(), v.Hi(), carry)
+	return NewUint128(hi, lo)
+}
+
+func (u Uint128) Sub(v Uint128) Uint128 {
+	lo, borrow := bits.Sub64(u.Lo(), v.Lo(), 0)
+	hi, _ := bits.Sub64(u.Hi(), v.Hi(), borrow)
+	return NewUint128(hi, lo)
+}
+
+func (u Uint128) Mul(v Uint128) Uint128 {
+	hi, lo := bits.Mul64(u.Lo(), v.Lo())
+	hi += u.Hi()*v.Lo() + u.Lo()*v.Hi()
+	return NewUint128(hi, lo)
+}
+
+// mul64 multiplies by a 64-bit factor and reports whether the product overflowed.
+func (u Uint128) mul64(v uint64) (Uint128, bool) {
+	carry, lo := bits.Mul64(u.Lo(), v)
+	overflow, hi := bits.Mul64(u.Hi(), v)
+	hi, c := bits.Add64(hi, carry, 0)
+	return NewUint128(hi, lo), overflow != 0 || c != 0
+}
+
+func (u Uint128) QuoRem64(v uint64) (Uint128, uint64) {
+	hi, r := u.Hi()/v, u.Hi()%v
+	lo, r := bits.Div64(r, u.Lo(), v)
+	return NewUint128(hi, lo), r
+}
+
+// QuoRem panics on division by zero, like the built-in integer division.
+func (u Uint128) QuoRem(v Uint128) (Uint128, Uint128) {
+	if v.Hi() == 0 {
+		q, r := u.QuoRem64(v.Lo())
+		return q, Uint128From64(r)
+	}
+	// Hacker's Delight 9-5: estimate the quotient from the normalized divisor, it is off by at most one
+	n := uint(bits.LeadingZeros64(v.Hi()))
+	v1 := v.Lsh(n)
+	u1 := u.Rsh(1)
+	tq, _ := bits.Div64(u1.Hi(), u1.Lo(), v1.Hi())
+	tq >>= 63 - n
+	if tq != 0 {
+		tq--
+	}
+	q := Uint128From64(tq)
+	r := u.Sub(v.Mul(q))
+	if r.Cmp(v) >= 0 {
+		q = q.Add(Uint128From64(1))
+		r = r.Sub(v)
+	}
+	return q, r
+}
+
+func (u Uint128) Lsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return Uint128{}
+	case n >= 64:
+		return NewUint128(u.Lo()<<(n-64), 0)
+	default:
+		return NewUint128(u.Hi()<<n|u.Lo()>>(64-n), u.Lo()<<n)
+	}
+}
+
+func (u Uint128) Rsh(n uint) Uint128 {
+	switch {
+	case n >= 128:
+		return Uint128{}
+	case n >= 64:
+		return NewUint128(0, u.Hi()>>(n-64))
+	default:
+		return NewUint128(u.Hi()>>n, u.Lo()>>n|u.Hi()<<(64-n))
+	}
+}
+
+func (u Uint128) And(v Uint128) Uint128 {
+	return NewUint128(u.Hi()&v.Hi(), u.Lo()&v.Lo())
+}
+
+func (u Uint128) Or(v Uint128) Uint128 {
+	return NewUint128(u.Hi()|v.Hi(), u.Lo()|v.Lo())
+}
+
+func (u Uint128) Xor(v Uint128) Uint128 {
+	return NewUint128(u.Hi()^v.Hi(), u.Lo()^v.Lo())
+}
+
+func (u Uint128) Not() Uint128 {
+	return NewUint128(^u.Hi(), ^u.Lo())
+}
+
+const uint128Digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// Text formats u in the given base, 2 <= base <= 36, like strconv.FormatUint.
+func (u Uint128) Text(base int) string {
+	if u.Hi() == 0 {
+		return strconv.FormatUint(u.Lo(), base)
+	}
+	var digits [128]byte
+	i := len(digits)
+	for !u.IsZero() {
+		var digit uint64
+		u, digit = u.QuoRem64(uint64(base))
+		i--
+		digits[i] = uint128Digits[digit]
+	}
+	return string(digits[i:])
+}
+
+func (u Uint128) String() string {
+	return u.Text(10)
+}
+
+// ParseUint128 works like strconv.ParseUint, base 0 detects 0x, 0o and 0b prefixes.
+func ParseUint128(s string, base int) (Uint128, error) {
+	const fn = "ParseUint128"
+	digits := s
+	if base == 0 {
+		base = 10
+		if len(s) > 2 && s[0] == '0' {
+			switch strings.ToLower(s[:2]) {
+			case "0x":
+				base, digits = 16, s[2:]
+			case "0o":
+				base, digits = 8, s[2:]
+			case "0b":
+				base, digits = 2, s[2:]
+			}
+		}
+	}
+	if base < 2 || base > 36 {
+		return Uint128{}, &strconv.NumError{Func: fn, Num: s, Err: strconv.ErrSyntax}
+	}
+	if digits == "" {
+		return Uint128{}, &strconv.NumError{Func: fn, Num: s, Err: strconv.ErrSyntax}
+	}
+
+	var result Uint128
+	for _, char := range []byte(digits) {
+		if 'A' <= char && char <= 'Z' {
+			char += 'a' - 'A'
+		}
+		digit := uint64(strings.IndexByte(uint128Digits, char))
+		if digit >= uint64(base) {
+			return Uint128{}, &strconv.NumError{Func: fn, Num: s, Err: strconv.ErrSyntax}
+		}
+		var overflow bool
+		result, overflow = result.mul64(uint64(base))
+		next := result.Add(Uint128From64(digit))
+		if overflow || next.Cmp(result) < 0 {
+			return NewUint128(math.MaxUint64, math.MaxUint64), &strconv.NumError{Func: fn, Num: s, Err: strconv.ErrRange}
+		}
+		result = next
+	}
+	return result, nil
+}
+
+func uint128ToBig(u Uint128) *big.Int {
+	b := u.Bytes()
+	return new(big.Int).SetBytes(b[:])
+}
+
+func uint128FromBig(b *big.Int) Uint128 {
+	var buf [16]byte
+	masked := new(big.Int).And(b, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)))
+	masked.FillBytes(buf[:])
+	return Uint128FromBytes(buf)
+}
+
+func randomUint128(r *rand.Rand) Uint128 {
+	// mix full-width and small values to exercise both division paths
+	switch r.IntN(3) {
+	case 0:
+		return Uint128From64(r.Uint64())
+	case 1:
+		return NewUint128(r.Uint64()>>r.IntN(64), r.Uint64())
+	default:
+		return NewUint128(r.Uint64(), r.Uint64())
+	}
+}
+
+func TestUint128Arithmetic(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for range 10000 {
+		u, v := randomUint128(r), randomUint128(r)
+		bu, bv := uint128ToBig(u), uint128ToBig(v)
+		shift := uint(r.IntN(130))
+
+		assert.Equal(t, uint128FromBig(new(big.Int).Add(bu, bv)), u.Add(v))
+		assert.Equal(t, uint128FromBig(new(big.Int).Sub(bu, bv)), u.Sub(v))
+		assert.Equal(t, uint128FromBig(new(big.Int).Mul(bu, bv)), u.Mul(v))
+		assert.Equal(t, uint128FromBig(new(big.Int).Lsh(bu, shift)), u.Lsh(shift))
+		assert.Equal(t, uint128FromBig(new(big.Int).Rsh(bu, shift)), u.Rsh(shift))
+		assert.Equal(t, bu.Cmp(bv), u.Cmp(v))
+		if !v.IsZero() {
+			q, m := u.QuoRem(v)
+			bq, bm := new(big.Int).QuoRem(bu, bv, new(big.Int))
+			assert.Equal(t, uint128FromBig(bq), q)
+			assert.Equal(t, uint128FromBig(bm), m)
+		}
+		assert.Equal(t, bu.String(), u.String())
+		assert.Equal(t, bu.Text(16), u.Text(16))
+	}
+
+	assert.Equal(t, Uint128{}, NewUint128(math.MaxUint64, math.MaxUint64).Add(Uint128From64(1)))
+	assert.Equal(t, NewUint128(0xF0, 0x0F), NewUint128(0xFF, 0x00).And(NewUint128(0xF0, 0xFF)).Or(Uint128From64(0x0F)))
+	assert.Equal(t, NewUint128(math.MaxUint64, math.MaxUint64), Uint128{}.Not().Xor(Uint128{}))
+	assert.Panics(t, func() { Uint128From64(1).QuoRem(Uint128{}) })
+}
+
+func TestParseUint128(t *testing.T) {
+	tests := map[string]struct {
+		input  string
+		base   int
+		result Uint128
+		err    error
+	}{
+		"decimal": {
+			input:  "340282366920938463463374607431768211455",
+			base:   10,
+			result: NewUint128(math.MaxUint64, math.MaxUint64),
+		},
+		"hex with prefix": {
+			input:  "0x0123456789abcdef0011223344556677",
+			base:   0,
+			result: NewUint128(0x0123456789ABCDEF, 0x0011223344556677),
+		},
+		"binary with prefix": {
+			input:  "0b101",
+			base:   0,
+			result: Uint128From64(5),
+		},
+		"small decimal": {
+			input:  "42",
+			base:   0,
+			result: Uint128From64(42),
+		},
+		"overflow": {
+			input:  "340282366920938463463374607431768211456",
+			base:   10,
+			result: NewUint128(math.MaxUint64, math.MaxUint64),
+			err:    strconv.ErrRange,
+		},
+		"invalid digit": {
+			input: "12a",
+			base:  10,
+			err:   strconv.ErrSyntax,
+		},
+		"control byte": {
+			input: "1\x12",
+			base:  10,
+			err:   strconv.ErrSyntax,
+		},
+		"lone control byte": {
+			input: "\x15",
+			base:  10,
+			err:   strconv.ErrSyntax,
+		},
+		"empty": {
+			input: "0x",
+			base:  0,
+			err:   strconv.ErrSyntax,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			result, err := ParseUint128(test.input, test.base)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.result, result)
+		})
+	}
+}
+
+func TestUint128Conversion(t *testing.T) {
+	number := NewUint128(0x0102030405060708, 0x090A0B0C0D0E0F10)
+	assert.Equal(t, NewUint128(0x100F0E0D0C0B0A09, 0x0807060504030201), number.ReverseBytes())
+	assert.Equal(t, number, number.ReverseBytes().ReverseBytes())
+	assert.Equal(t, [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, number.Bytes())
+	assert.Equal(t, number, number.HostToNetwork().NetworkToHost())
+
+	reversed := number.ReverseBytes()
+	memory, reversedMemory := *(*[16]byte)(unsafe.Pointer(&number)), *(*[16]byte)(unsafe.Pointer(&reversed))
+	for i := range memory {
+		assert.Equal(t, memory[i], reversedMemory[len(memory)-1-i])
+	}
+
+	ip := netip.MustParseAddr("2001:db8::ff00:42:8329")
+	address := Uint128FromBytes(ip.As16())
+	assert.Equal(t, "20010db8000000000000ff0000428329", address.Text(16))
+	assert.Equal(t, ip, netip.AddrFrom16(address.Bytes()))
+
+	next := netip.AddrFrom16(address.Add(Uint128From64(1)).Bytes())
+	assert.Equal(t, ip.Next(), next)
+}
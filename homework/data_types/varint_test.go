@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var ErrVarintOverflow = errors.New("varint overflows target type")
+
+const (
+	varintPayloadMask = 0x7F
+	varintNextMask    = 0x80
+	varintSignMask    = 0x40
+	varintGroupBits   = 7
+)
+
+func bitWidth[T NumberType]() int {
+	var zero T
+	return int(unsafe.Sizeof(zero)) * 8
+}
+
+type sliceByteReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *sliceByteReader) ReadByte() (byte, error) {
+	if r.pos >= len(r.buf) {
+		return 0, io.EOF
+	}
+	r.pos++
+	return r.buf[r.pos-1], nil
+}
+
+// decodeFromSlice runs a streaming decoder over buf and reports how many bytes it consumed.
+func decodeFromSlice[T NumberType](buf []byte, read func(io.ByteReader) (T, error)) (T, int, error) {
+	reader := &sliceByteReader{buf: buf}
+	number, err := read(reader)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	return number, reader.pos, err
+}
+
+// readNext returns io.ErrUnexpectedEOF when the stream ends in the middle of a value.
+func readNext(r io.ByteReader) (byte, error) {
+	b, err := r.ReadByte()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+func AppendUleb128[T UintType](buf []byte, number T) []byte {
+	value := uint64(number)
+	for value >= varintNextMask {
+		buf = append(buf, byte(value&varintPayloadMask)|varintNextMask)
+		value >>= varintGroupBits
+	}
+	return append(buf, byte(value))
+}
+
+func ReadUleb128[T UintType](r io.ByteReader) (T, error) {
+	width := bitWidth[T]()
+	var result uint64
+	for shift := 0; ; shift += varintGroupBits {
+		var (
+			b   byte
+			err error
+		)
+		if shift == 0 {
+			b, err = r.ReadByte()
+		} else {
+			b, err = readNext(r)
+		}
+		if err != nil {
+			return 0, err
+		}
+		payload := uint64(b & varintPayloadMask)
+		if shift >= width || (width-shift < varintGroupBits && payload>>(width-shift) != 0) {
+			return 0, fmt.Errorf("%w: uleb128 does not fit into %d bits", ErrVarintOverflow, width)
+		}
+		result |= payload << shift
+		if b&varintNextMask == 0 {
+			return T(result), nil
+		}
+	}
+}
+
+func DecodeUleb128[T UintType](buf []byte) (T, int, error) {
+	return decodeFromSlice(buf, ReadUleb128[T])
+}
+
+func AppendSleb128[T IntType](buf []byte, number T) []byte {
+	value := int64(number)
+	for {
+		b := byte(value & varintPayloadMask)
+		value >>= varintGroupBits
+		if (value == 0 && b&varintSignMask == 0) || (value == -1 && b&varintSignMask != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|varintNextMask)
+	}
+}
+
+func ReadSleb128[T IntType](r io.ByteReader) (T, error) {
+	width := bitWidth[T]()
+	var result int64
+	for shift := 0; ; shift += varintGroupBits {
+		var (
+			b   byte
+			err error
+		)
+		if shift == 0 {
+			b, err = r.ReadByte()
+		} else {
+			b, err = readNext(r)
+		}
+		if err != nil {
+			return 0, err
+		}
+		payload := b & varintPayloadMask
+		// the tenth byte carries only the 64th bit, the rest must repeat the sign
+		if shift >= 64 || (shift == 63 && payload != 0 && payload != varintPayloadMask) {
+			return 0, fmt.Errorf("%w: sleb128 does not fit into %d bits", ErrVarintOverflow, width)
+		}
+		result |= int64(payload) << shift
+		if b&varintNextMask != 0 {
+			continue
+		}
+		if shift+varintGroupBits < 64 && payload&varintSignMask != 0 {
+			result |= -1 << (shift + varintGroupBits)
+		}
+		if int64(T(result)) != result {
+			return 0, fmt.Errorf("%w: sleb128 value %d does not fit into %d bits", ErrVarintOverflow, result, width)
+		}
+		return T(result), nil
+	}
+}
+
+func DecodeSleb128[T IntType](buf []byte) (T, int, error) {
+	return decodeFromSlice(buf, ReadSleb128[T])
+}
+
+// ZigZagEncode maps signed values to unsigned ones so that small magnitudes stay small: 0, -1, 1, -2 -> 0, 1, 2, 3.
+func ZigZagEncode[T IntType](number T) uint64 {
+	value := int64(number)
+	return uint64(value<<1) ^ uint64(value>>63)
+}
+
+func ZigZagDecode[T IntType](encoded uint64) (T, error) {
+	width := bitWidth[T]()
+	if width < 64 && encoded>>width != 0 {
+		return 0, fmt.Errorf("%w: zigzag value %d does not fit into %d bits", ErrVarintOverflow, encoded, width)
+	}
+	return T(int64(encoded>>1) ^ -int64(encoded&1)), nil
+}
+
+// Prefix varint stores the total length as leading one bits of the first byte,
+// so a reader knows the size after one byte: 0xxxxxxx, 10xxxxxx xxxxxxxx, ...,
+// 11111110 + 7 bytes, 11111111 + 8 bytes. The payload is big-endian.
+const prefixVarintMaxLength = 9
+
+func AppendPrefixVarint[T UintType](buf []byte, number T) []byte {
+	value := uint64(number)
+	length := 1
+	for length < prefixVarintMaxLength-1 && value >= 1<<(varintGroupBits*length) {
+		length++
+	}
+	if value >= 1<<(varintGroupBits*length) {
+		length = prefixVarintMaxLength
+	}
+
+	var encoded [prefixVarintMaxLength]byte
+	putNumber(encoded[1:], value, BigEndian)
+	payload := encoded[prefixVarintMaxLength-length:]
+	if length < prefixVarintMaxLength {
+		payload[0] |= ^byte(0xFF >> (length - 1))
+	} else {
+		payload[0] = 0xFF
+	}
+	return append(buf, payload...)
+}
+
+func ReadPrefixVarint[T UintType](r io.ByteReader) (T, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	length := 1
+	for length < prefixVarintMaxLength && first&(0x80>>(length-1)) != 0 {
+		length++
+	}
+
+	var value uint64
+	if length < prefixVarintMaxLength {
+		value = uint64(first & (0x7F >> (length - 1)))
+	}
+	for range length - 1 {
+		b, err := readNext(r)
+		if err != nil {
+			return 0, err
+		}
+		value = value<<8 | uint64(b)
+	}
+
+	width := bitWidth[T]()
+	if width < 64 && value>>width != 0 {
+		return 0, fmt.Errorf("%w: prefix varint does not fit into %d bits", ErrVarintOverflow, width)
+	}
+	return T(value), nil
+}
+
+func DecodePrefixVarint[T UintType](buf []byte) (T, int, error) {
+	return decodeFromSlice(buf, ReadPrefixVarint[T])
+}
+
+func TestUleb128(t *testing.T) {
+	tests := map[string]struct {
+		number  uint64
+		encoded []byte
+	}{
+		"zero":         {number: 0, encoded: []byte{0x00}},
+		"one byte max": {number: 127, encoded: []byte{0x7F}},
+		"two bytes":    {number: 128, encoded: []byte{0x80, 0x01}},
+		"wikipedia":    {number: 624485, encoded: []byte{0xE5, 0x8E, 0x26}},
+		"max uint64": {
+			number:  math.MaxUint64,
+			encoded: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded := AppendUleb128(nil, test.number)
+			assert.Equal(t, test.encoded, encoded)
+
+			number, n, err := DecodeUleb128[uint64](encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, test.number, number)
+			assert.Equal(t, len(encoded), n)
+		})
+	}
+
+	_, _, err := DecodeUleb128[uint8]([]byte{0x80, 0x02})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+	_, _, err = DecodeUleb128[uint16]([]byte{0x80, 0x80, 0x80, 0x00})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+	_, _, err = DecodeUleb128[uint64]([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+	_, _, err = DecodeUleb128[uint32]([]byte{0x80})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSleb128(t *testing.T) {
+	tests := map[string]struct {
+		number  int64
+		encoded []byte
+	}{
+		"zero":         {number: 0, encoded: []byte{0x00}},
+		"minus one":    {number: -1, encoded: []byte{0x7F}},
+		"sign bit set": {number: 64, encoded: []byte{0xC0, 0x00}},
+		"wikipedia":    {number: -123456, encoded: []byte{0xC0, 0xBB, 0x78}},
+		"min int64": {
+			number:  math.MinInt64,
+			encoded: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7F},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded := AppendSleb128(nil, test.number)
+			assert.Equal(t, test.encoded, encoded)
+
+			number, n, err := DecodeSleb128[int64](encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, test.number, number)
+			assert.Equal(t, len(encoded), n)
+		})
+	}
+
+	_, _, err := DecodeSleb128[int8]([]byte{0x80, 0x01})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+	_, _, err = DecodeSleb128[int64]([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x02})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+}
+
+func TestZigZag(t *testing.T) {
+	for number, encoded := range map[int64]uint64{0: 0, -1: 1, 1: 2, -2: 3, math.MaxInt64: math.MaxUint64 - 1, math.MinInt64: math.MaxUint64} {
+		assert.Equal(t, encoded, ZigZagEncode(number))
+		decoded, err := ZigZagDecode[int64](encoded)
+		assert.NoError(t, err)
+		assert.Equal(t, number, decoded)
+	}
+
+	assert.Equal(t, uint64(255), ZigZagEncode(int8(math.MinInt8)))
+	_, err := ZigZagDecode[int8](256)
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+}
+
+func TestPrefixVarint(t *testing.T) {
+	tests := map[string]struct {
+		number  uint64
+		encoded []byte
+	}{
+		"zero":            {number: 0, encoded: []byte{0x00}},
+		"one byte max":    {number: 0x7F, encoded: []byte{0x7F}},
+		"two bytes":       {number: 0x80, encoded: []byte{0x80, 0x80}},
+		"three bytes":     {number: 0x4000, encoded: []byte{0xC0, 0x40, 0x00}},
+		"eight bytes max": {number: 1<<56 - 1, encoded: []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}},
+		"nine bytes": {
+			number:  1 << 56,
+			encoded: []byte{0xFF, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			encoded := AppendPrefixVarint(nil, test.number)
+			assert.Equal(t, test.encoded, encoded)
+
+			number, n, err := DecodePrefixVarint[uint64](encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, test.number, number)
+			assert.Equal(t, len(encoded), n)
+		})
+	}
+
+	_, _, err := DecodePrefixVarint[uint16]([]byte{0xC4, 0x00, 0x00})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+	_, _, err = DecodePrefixVarint[uint64]([]byte{0xFF, 0x00})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestVarintStream(t *testing.T) {
+	var buf []byte
+	buf = AppendUleb128(buf, uint32(300))
+	buf = AppendSleb128(buf, int16(-300))
+	buf = AppendPrefixVarint(buf, uint64(1<<40))
+	buf = AppendUleb128(buf, ZigZagEncode(int32(-7)))
+
+	reader := bytes.NewReader(buf)
+	unsigned, err := ReadUleb128[uint32](reader)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(300), unsigned)
+
+	signed, err := ReadSleb128[int16](reader)
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-300), signed)
+
+	prefixed, err := ReadPrefixVarint[uint64](reader)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<40), prefixed)
+
+	zigzag, err := ReadUleb128[uint64](reader)
+	assert.NoError(t, err)
+	decoded, err := ZigZagDecode[int32](zigzag)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(-7), decoded)
+
+	_, err = ReadUleb128[uint64](reader)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func roundTripUnsigned[T UintType](t *testing.T, number T) {
+	decoded, n, err := DecodeUleb128[T](AppendUleb128(nil, number))
+	if err != nil || decoded != number || n != len(AppendUleb128(nil, number)) {
+		t.Fatalf("uleb128 %T(%d): got %d, %d bytes, %v", number, number, decoded, n, err)
+	}
+	decoded, n, err = DecodePrefixVarint[T](AppendPrefixVarint(nil, number))
+	if err != nil || decoded != number || n != len(AppendPrefixVarint(nil, number)) {
+		t.Fatalf("prefix varint %T(%d): got %d, %d bytes, %v", number, number, decoded, n, err)
+	}
+}
+
+func roundTripSigned[T IntType](t *testing.T, number T) {
+	decoded, n, err := DecodeSleb128[T](AppendSleb128(nil, number))
+	if err != nil || decoded != number || n != len(AppendSleb128(nil, number)) {
+		t.Fatalf("sleb128 %T(%d): got %d, %d bytes, %v", number, number, decoded, n, err)
+	}
+	zigzag, err := ZigZagDecode[T](ZigZagEncode(number))
+	if err != nil || zigzag != number {
+		t.Fatalf("zigzag %T(%d): got %d, %v", number, number, zigzag, err)
+	}
+}
+
+func FuzzVarintRoundTrip(f *testing.F) {
+	for _, seed := range []uint64{0, 1, 127, 128, 1 << 14, 1<<56 - 1, 1 << 56, math.MaxInt64, math.MaxUint64} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, number uint64) {
+		roundTripUnsigned(t, uint8(number))
+		roundTripUnsigned(t, uint16(number))
+		roundTripUnsigned(t, uint32(number))
+		roundTripUnsigned(t, number)
+		roundTripUnsigned(t, uintptr(number))
+		roundTripSigned(t, int8(number))
+		roundTripSigned(t, int16(number))
+		roundTripSigned(t, int32(number))
+		roundTripSigned(t, int64(number))
+	})
+}
+
+func FuzzVarintDecode(f *testing.F) {
+	f.Add([]byte{0x80, 0x01})
+	f.Add([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if number, n, err := DecodeUleb128[uint64](data); err == nil {
+			again, _, err := DecodeUleb128[uint64](AppendUleb128(nil, number))
+			assert.NoError(t, err)
+			assert.Equal(t, number, again)
+			assert.LessOrEqual(t, n, len(data))
+		}
+		if number, _, err := DecodeSleb128[int32](data); err == nil {
+			again, _, err := DecodeSleb128[int32](AppendSleb128(nil, number))
+			assert.NoError(t, err)
+			assert.Equal(t, number, again)
+		}
+		if number, n, err := DecodePrefixVarint[uint64](data); err == nil {
+			encoded := AppendPrefixVarint(nil, number)
+			assert.LessOrEqual(t, len(encoded), n)
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SetDefaultFormatter changes the formatter of every MultiError without its own one, nil restores BulletFormatter.
+func SetDefaultFormatter(formatter ErrorFormatter) {
+	if formatter == nil {
+		defaultFormatter.Store(nil)
+		return
+	}
+	defaultFormatter.Store(&formatter)
+}
+
+// WithFormatter sets the formatter of this instance only, nil falls back to the default one.
+func (e *MultiError) WithFormatter(formatter ErrorFormatter) *MultiError {
+	e.formatter = formatter
+	return e
+}
+
+func SingleLineFormatter(errs []error) string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+func JSONFormatter(errs []error) string {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	data, _ := json.Marshal(messages)
+	return string(data)
+}
+
+func TestMultiErrorFormatters(t *testing.T) {
+	err := Append(nil, errors.New("error 1"), errors.New(`error "2"`))
+
+	tests := map[string]struct {
+		formatter ErrorFormatter
+		result    string
+	}{
+		"default":     {formatter: nil, result: "2 errors occured:\n\t* error 1\t* error \"2\"\n"},
+		"bullets":     {formatter: BulletFormatter, result: "2 errors occured:\n\t* error 1\t* error \"2\"\n"},
+		"single line": {formatter: SingleLineFormatter, result: "error 1; error \"2\""},
+		"json":        {formatter: JSONFormatter, result: `["error 1","error \"2\""]`},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.EqualError(t, err.WithFormatter(test.formatter), test.result)
+		})
+	}
+
+	err.WithFormatter(SingleLineFormatter)
+	err = Append(err, errors.New("error 3"))
+	assert.EqualError(t, err, "error 1; error \"2\"; error 3")
+	assert.EqualError(t, err.Filter(func(error) bool { return true }), "error 1; error \"2\"; error 3")
+	assert.Equal(t, "", (&MultiError{}).WithFormatter(JSONFormatter).Error())
+}
+
+// the default formatter is global, so this test must not run in parallel with the others
+func TestMultiErrorDefaultFormatter(t *testing.T) {
+	defer SetDefaultFormatter(nil)
+
+	own := Append(nil, errors.New("error 1"), errors.New("error 2")).WithFormatter(JSONFormatter)
+	shared := Append(nil, errors.New("error 1"), errors.New("error 2"))
+
+	SetDefaultFormatter(SingleLineFormatter)
+	assert.EqualError(t, shared, "error 1; error 2")
+	assert.EqualError(t, own, `["error 1","error 2"]`)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = shared.Error()
+		}()
+	}
+	SetDefaultFormatter(func(errs []error) string { return "custom" })
+	wg.Wait()
+	assert.EqualError(t, shared, "custom")
+
+	SetDefaultFormatter(nil)
+	assert.EqualError(t, shared, "2 errors occured:\n\t* error 1\t* error 2\n")
+}
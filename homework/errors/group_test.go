@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ErrorGroup runs goroutines and collects all their errors, the zero value is ready to use.
+type ErrorGroup struct {
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	err    *MultiError
+	cancel context.CancelCauseFunc
+	limit  chan struct{}
+}
+
+// WithContext returns a group whose context is cancelled by the first collected error.
+func WithContext(ctx context.Context) (*ErrorGroup, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &ErrorGroup{cancel: cancel}, ctx
+}
+
+// SetLimit bounds the number of running goroutines, a negative limit removes the bound.
+// It must not be called while goroutines are running.
+func (g *ErrorGroup) SetLimit(n int) {
+	if n < 0 {
+		g.limit = nil
+		return
+	}
+	g.limit = make(chan struct{}, n)
+}
+
+// Go blocks while the limit of running goroutines is reached.
+func (g *ErrorGroup) Go(f func() error) {
+	if g.limit != nil {
+		g.limit <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.limit != nil {
+			defer func() { <-g.limit }()
+		}
+		g.Add(f())
+	}()
+}
+
+func (g *ErrorGroup) Add(err error) {
+	if err == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.err == nil && g.cancel != nil {
+		g.cancel(err)
+	}
+	g.err = Append(g.err, err)
+}
+
+// Wait returns nil when no goroutine failed.
+func (g *ErrorGroup) Wait() *MultiError {
+	g.wg.Wait()
+	if g.cancel != nil {
+		g.cancel(nil)
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.err
+}
+
+func TestErrorGroup(t *testing.T) {
+	var group ErrorGroup
+	for i := range 100 {
+		group.Go(func() error {
+			if i%10 == 0 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+	}
+	group.Add(nil)
+
+	merr := group.Wait()
+	assert.NotNil(t, merr)
+	assert.Len(t, merr.Unwrap(), 10)
+
+	var empty ErrorGroup
+	empty.Go(func() error { return nil })
+	assert.Nil(t, empty.Wait())
+}
+
+func TestErrorGroupCancellation(t *testing.T) {
+	errFirst := errors.New("first")
+	group, ctx := WithContext(context.Background())
+
+	group.Go(func() error {
+		return errFirst
+	})
+	group.Go(func() error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	merr := group.Wait()
+	assert.ErrorIs(t, merr, errFirst)
+	assert.ErrorIs(t, merr, context.Canceled)
+	assert.Equal(t, errFirst, context.Cause(ctx))
+}
+
+func TestErrorGroupLimit(t *testing.T) {
+	const limit = 3
+	var running, maxRunning atomic.Int32
+
+	var group ErrorGroup
+	group.SetLimit(limit)
+	for range 20 {
+		group.Go(func() error {
+			current := running.Add(1)
+			for {
+				observed := maxRunning.Load()
+				if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+
+	assert.Nil(t, group.Wait())
+	assert.LessOrEqual(t, maxRunning.Load(), int32(limit))
+	assert.Positive(t, maxRunning.Load())
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"errors"
+	"io"
+	"io/fs"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,36 +15,108 @@ import (
 // go test -v homework_test.go
 
 type MultiError struct {
-	errors []error
+	errors    []error
+	formatter ErrorFormatter
 }
 
 func (e *MultiError) Error() string {
-	if len(e.errors) == 0 {
+	if e == nil || len(e.errors) == 0 {
 		return ""
 	}
+	formatter := e.formatter
+	if formatter == nil {
+		formatter = DefaultFormatter()
+	}
+	return formatter(e.errors)
+}
+
+// ErrorFormatter renders the errors of a non-empty MultiError.
+type ErrorFormatter func(errs []error) string
+
+var defaultFormatter atomic.Pointer[ErrorFormatter]
+
+func DefaultFormatter() ErrorFormatter {
+	if formatter := defaultFormatter.Load(); formatter != nil {
+		return *formatter
+	}
+	return BulletFormatter
+}
+
+func BulletFormatter(errs []error) string {
 	strError := strings.Builder{}
-	strError.WriteString(strconv.Itoa(len(e.errors)))
+	strError.WriteString(strconv.Itoa(len(errs)))
 	strError.WriteString(" errors occured:\n")
-	for _, err := range e.errors {
+	for _, err := range errs {
 		strError.WriteString("\t* " + err.Error())
 	}
 	strError.WriteString("\n")
 	return strError.String()
 }
 
+func (e *MultiError) Unwrap() []error {
+	return e.errors
+}
+
+// Append flattens nested MultiErrors and skips nil errors.
 func Append(err error, errs ...error) *MultiError {
 	merr, ok := err.(*MultiError)
-	if !ok {
-		if err == nil {
-			merr = &MultiError{errors: errs}
-			return merr
-		}
-		merr = &MultiError{errors: []error{err}}
+	if !ok || merr == nil {
+		merr = &MultiError{errors: appendFlattened(nil, err)}
 	}
-	merr.errors = append(merr.errors, errs...)
+	merr.errors = appendFlattened(merr.errors, errs...)
 	return merr
 }
 
+func appendFlattened(dst []error, errs ...error) []error {
+	for _, err := range errs {
+		switch e := err.(type) {
+		case nil:
+		case *MultiError:
+			if e != nil {
+				dst = appendFlattened(dst, e.errors...)
+			}
+		default:
+			dst = append(dst, err)
+		}
+	}
+	return dst
+}
+
+// ErrorOrNil returns an untyped nil when there is nothing to report.
+func (e *MultiError) ErrorOrNil() error {
+	if e == nil || len(e.errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Dedup returns a MultiError without errors whose messages were already seen.
+func (e *MultiError) Dedup() *MultiError {
+	seen := make(map[string]struct{})
+	return e.Filter(func(err error) bool {
+		if _, ok := seen[err.Error()]; ok {
+			return false
+		}
+		seen[err.Error()] = struct{}{}
+		return true
+	})
+}
+
+// Filter returns a MultiError with the errors keep reports true for.
+func (e *MultiError) Filter(keep func(error) bool) *MultiError {
+	result := &MultiError{}
+	if e == nil {
+		return result
+	}
+	result.formatter = e.formatter
+	for _, err := range e.errors {
+		if keep(err) {
+			result.errors = append(result.errors, err)
+		}
+	}
+	return result
+}
+
 func TestMultiError(t *testing.T) {
 	var err error
 	err = Append(err, errors.New("error 1"))
@@ -50,3 +125,75 @@ func TestMultiError(t *testing.T) {
 	expectedMessage := "2 errors occured:\n\t* error 1\t* error 2\n"
 	assert.EqualError(t, err, expectedMessage)
 }
+
+func TestMultiErrorUnwrap(t *testing.T) {
+	pathErr := &fs.PathError{Op: "open", Path: "/tmp/file", Err: fs.ErrNotExist}
+	inner := Append(nil, errors.New("error 1"), pathErr)
+	var err error = &MultiError{errors: []error{errors.New("error 2"), inner, io.EOF}}
+
+	assert.ErrorIs(t, err, io.EOF)
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+	assert.NotErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	var target *fs.PathError
+	assert.ErrorAs(t, err, &target)
+	assert.Equal(t, pathErr, target)
+
+	var nested *MultiError
+	assert.ErrorAs(t, err, &nested)
+	assert.Len(t, nested.Unwrap(), 3)
+
+	assert.Nil(t, (&MultiError{}).Unwrap())
+	assert.NotErrorIs(t, &MultiError{}, io.EOF)
+}
+
+func TestAppendToNilMultiError(t *testing.T) {
+	var merr *MultiError
+	merr = Append(merr, errors.New("error 1"))
+	assert.EqualError(t, merr, "1 errors occured:\n\t* error 1\n")
+}
+
+func TestAppendFlattens(t *testing.T) {
+	first := Append(nil, errors.New("error 1"), nil)
+	second := Append(errors.New("error 2"), Append(nil, errors.New("error 3")))
+	err := Append(first, second, nil, errors.New("error 4"))
+
+	assert.Len(t, err.Unwrap(), 4)
+	assert.EqualError(t, err, "4 errors occured:\n\t* error 1\t* error 2\t* error 3\t* error 4\n")
+}
+
+func TestMultiErrorDedupAndFilter(t *testing.T) {
+	err := Append(nil, errors.New("error 1"), io.EOF, errors.New("error 1"), io.EOF, errors.New("error 2"))
+
+	deduped := err.Dedup()
+	assert.EqualError(t, deduped, "3 errors occured:\n\t* error 1\t* EOF\t* error 2\n")
+	assert.Len(t, err.Unwrap(), 5)
+
+	filtered := err.Filter(func(err error) bool {
+		return !errors.Is(err, io.EOF)
+	})
+	assert.EqualError(t, filtered, "3 errors occured:\n\t* error 1\t* error 1\t* error 2\n")
+
+	none := err.Filter(func(error) bool { return false })
+	assert.NoError(t, none.ErrorOrNil())
+}
+
+func TestMultiErrorErrorOrNil(t *testing.T) {
+	tests := map[string]struct {
+		merr  *MultiError
+		isNil bool
+	}{
+		"nil pointer":  {merr: nil, isNil: true},
+		"empty":        {merr: &MultiError{}, isNil: true},
+		"only nils":    {merr: Append(nil, nil, nil), isNil: true},
+		"with errors":  {merr: Append(nil, io.EOF), isNil: false},
+		"nested empty": {merr: Append(&MultiError{}, &MultiError{}), isNil: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.merr.ErrorOrNil()
+			assert.Equal(t, test.isNil, err == nil)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// CodedError exposes a machine-readable code to API clients.
+type CodedError interface {
+	error
+	Code() string
+}
+
+// FieldError names the request field that caused the error.
+type FieldError interface {
+	error
+	Field() string
+}
+
+// APIError is what a client gets back after decoding a MultiError.
+type APIError struct {
+	message string
+	code    string
+	field   string
+}
+
+func NewAPIError(message, code, field string) *APIError {
+	return &APIError{message: message, code: code, field: field}
+}
+
+func (e *APIError) Error() string {
+	return e.message
+}
+
+func (e *APIError) Code() string {
+	return e.code
+}
+
+func (e *APIError) Field() string {
+	return e.field
+}
+
+type jsonError struct {
+	Message string `json:"message"`
+	Code    string `json:"code,omitempty"`
+	Field   string `json:"field,omitempty"`
+}
+
+// MarshalJSON encodes a nil MultiError as null, like a nil slice.
+func (e *MultiError) MarshalJSON() ([]byte, error) {
+	if e == nil {
+		return []byte("null"), nil
+	}
+	items := make([]jsonError, 0, len(e.errors))
+	for _, err := range e.errors {
+		item := jsonError{Message: err.Error()}
+		var coded CodedError
+		if errors.As(err, &coded) {
+			item.Code = coded.Code()
+		}
+		var field FieldError
+		if errors.As(err, &field) {
+			item.Field = field.Field()
+		}
+		items = append(items, item)
+	}
+	return json.Marshal(items)
+}
+
+// UnmarshalJSON replaces the errors with APIErrors, the formatter is kept.
+func (e *MultiError) UnmarshalJSON(data []byte) error {
+	var items []jsonError
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	e.errors = nil
+	for _, item := range items {
+		e.errors = append(e.errors, NewAPIError(item.Message, item.Code, item.Field))
+	}
+	return nil
+}
+
+type validationError struct {
+	field string
+}
+
+func (e validationError) Error() string {
+	return e.field + " is required"
+}
+
+func (e validationError) Code() string {
+	return "required"
+}
+
+func (e validationError) Field() string {
+	return e.field
+}
+
+func TestMultiErrorJSON(t *testing.T) {
+	merr := Append(nil,
+		validationError{field: "name"},
+		fmt.Errorf("request: %w", validationError{field: "age"}),
+		errors.New("internal error"),
+	)
+
+	data, err := json.Marshal(merr)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"message": "name is required", "code": "required", "field": "name"},
+		{"message": "request: age is required", "code": "required", "field": "age"},
+		{"message": "internal error"}
+	]`, string(data))
+
+	response, err := json.Marshal(struct {
+		Errors *MultiError `json:"errors"`
+	}{Errors: Append(nil, errors.New("error 1"))})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"errors":[{"message":"error 1"}]}`, string(response))
+
+	var decoded MultiError
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.EqualError(t, &decoded, "3 errors occured:\n\t* name is required\t* request: age is required\t* internal error\n")
+
+	var coded CodedError
+	assert.ErrorAs(t, &decoded, &coded)
+	assert.Equal(t, "required", coded.Code())
+
+	var fields []string
+	for _, err := range decoded.Unwrap() {
+		fields = append(fields, err.(FieldError).Field())
+	}
+	assert.Equal(t, []string{"name", "age", ""}, fields)
+
+	again, err := json.Marshal(&decoded)
+	assert.NoError(t, err)
+	assert.JSONEq(t, string(data), string(again))
+}
+
+func TestMultiErrorJSONEdgeCases(t *testing.T) {
+	data, err := json.Marshal(&MultiError{})
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", string(data))
+
+	data, err = (*MultiError)(nil).MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, "null", string(data))
+	data, err = json.Marshal(struct{ Errors *MultiError }{})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Errors":null}`, string(data))
+
+	var merr MultiError
+	assert.NoError(t, json.Unmarshal([]byte("[]"), &merr))
+	assert.NoError(t, merr.ErrorOrNil())
+
+	assert.Error(t, json.Unmarshal([]byte(`{"message":"not an array"}`), &merr))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Backoff returns the delay before the given retry, the first retry is 1.
+type Backoff func(retry int) time.Duration
+
+func ConstantBackoff(delay time.Duration) Backoff {
+	return func(int) time.Duration {
+		return delay
+	}
+}
+
+// ExponentialBackoff doubles the delay after every retry up to maxDelay.
+func ExponentialBackoff(initial, maxDelay time.Duration) Backoff {
+	return func(retry int) time.Duration {
+		delay := initial
+		for range retry - 1 {
+			if delay >= maxDelay/2 {
+				return maxDelay
+			}
+			delay *= 2
+		}
+		return min(delay, maxDelay)
+	}
+}
+
+// WithJitter spreads every delay randomly over [delay*(1-fraction), delay*(1+fraction)].
+func WithJitter(backoff Backoff, fraction float64) Backoff {
+	return func(retry int) time.Duration {
+		delay := float64(backoff(retry))
+		return time.Duration(delay * (1 - fraction + 2*fraction*rand.Float64()))
+	}
+}
+
+type Classification int
+
+const (
+	Retryable Classification = iota
+	Permanent
+)
+
+type Classifier func(err error) Classification
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// MarkPermanent makes DefaultClassifier stop retrying on err.
+func MarkPermanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+func DefaultClassifier(err error) Classification {
+	var permanent permanentError
+	if errors.As(err, &permanent) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return Permanent
+	}
+	return Retryable
+}
+
+type AttemptError struct {
+	Attempt int
+	Time    time.Time
+	Err     error
+}
+
+func (e *AttemptError) Error() string {
+	return fmt.Sprintf("attempt %d at %s: %s", e.Attempt, e.Time.Format(time.RFC3339Nano), e.Err)
+}
+
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// Defaults used for the zero fields of a RetryPolicy.
+const (
+	DefaultMaxAttempts  = 5
+	DefaultInitialDelay = 10 * time.Millisecond
+	DefaultMaxDelay     = time.Second
+)
+
+type RetryPolicy struct {
+	MaxAttempts int     // zero means DefaultMaxAttempts, negative retries until success, a permanent error or cancellation
+	Backoff     Backoff // nil means ExponentialBackoff(DefaultInitialDelay, DefaultMaxDelay)
+	Classify    Classifier
+}
+
+// Retry returns nil on success, otherwise a *MultiError with an AttemptError for every failed attempt
+// followed by the context error if the context was cancelled. The result is typed error rather than
+// *MultiError so a successful call compares equal to nil, use errors.As to get at the attempts.
+func Retry(ctx context.Context, policy RetryPolicy, operation func(context.Context) error) error {
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	backoff := policy.Backoff
+	if backoff == nil {
+		backoff = ExponentialBackoff(DefaultInitialDelay, DefaultMaxDelay)
+	}
+	classify := policy.Classify
+	if classify == nil {
+		classify = DefaultClassifier
+	}
+
+	var merr *MultiError
+	for attempt := 1; ; attempt++ {
+		if err := context.Cause(ctx); err != nil {
+			return Append(merr, err)
+		}
+		err := operation(ctx)
+		if err == nil {
+			return nil
+		}
+		merr = Append(merr, &AttemptError{Attempt: attempt, Time: time.Now(), Err: err})
+		if classify(err) == Permanent || attempt == maxAttempts {
+			return merr
+		}
+
+		timer := time.NewTimer(backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return Append(merr, context.Cause(ctx))
+		case <-timer.C:
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	constant := ConstantBackoff(time.Second)
+	assert.Equal(t, time.Second, constant(1))
+	assert.Equal(t, time.Second, constant(10))
+
+	exponential := ExponentialBackoff(100*time.Millisecond, time.Second)
+	assert.Equal(t, 100*time.Millisecond, exponential(1))
+	assert.Equal(t, 200*time.Millisecond, exponential(2))
+	assert.Equal(t, 800*time.Millisecond, exponential(4))
+	assert.Equal(t, time.Second, exponential(5))
+	assert.Equal(t, time.Second, exponential(100))
+
+	jitter := WithJitter(constant, 0.5)
+	for retry := range 100 {
+		delay := jitter(retry)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, 1500*time.Millisecond)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	errFatal := errors.New("fatal")
+
+	tests := map[string]struct {
+		results  []error
+		policy   RetryPolicy
+		attempts int
+		failures int
+		last     error
+	}{
+		"success on first attempt": {
+			results:  []error{nil},
+			policy:   RetryPolicy{MaxAttempts: 3},
+			attempts: 1,
+		},
+		"success after retries": {
+			results:  []error{errTemporary, errTemporary, nil},
+			policy:   RetryPolicy{MaxAttempts: 3, Backoff: ConstantBackoff(time.Millisecond)},
+			attempts: 3,
+		},
+		"attempts exhausted": {
+			results:  []error{errTemporary, errTemporary, errTemporary, nil},
+			policy:   RetryPolicy{MaxAttempts: 3, Backoff: ExponentialBackoff(time.Millisecond, 2*time.Millisecond)},
+			attempts: 3,
+			failures: 3,
+			last:     errTemporary,
+		},
+		"stop on permanent error": {
+			results:  []error{errTemporary, MarkPermanent(errFatal), nil},
+			policy:   RetryPolicy{MaxAttempts: 5},
+			attempts: 2,
+			failures: 2,
+			last:     errFatal,
+		},
+		"zero policy is bounded": {
+			results:  []error{errTemporary, errTemporary, errTemporary, errTemporary, errTemporary, nil},
+			policy:   RetryPolicy{},
+			attempts: DefaultMaxAttempts,
+			failures: DefaultMaxAttempts,
+			last:     errTemporary,
+		},
+		"custom classifier": {
+			results: []error{errTemporary, errFatal, nil},
+			policy: RetryPolicy{Classify: func(err error) Classification {
+				if errors.Is(err, errFatal) {
+					return Permanent
+				}
+				return Retryable
+			}},
+			attempts: 2,
+			failures: 2,
+			last:     errFatal,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			start := time.Now()
+			err := Retry(context.Background(), test.policy, func(context.Context) error {
+				attempts++
+				return test.results[attempts-1]
+			})
+			assert.Equal(t, test.attempts, attempts)
+			if test.failures == 0 {
+				assert.NoError(t, err)
+				return
+			}
+
+			var merr *MultiError
+			assert.ErrorAs(t, err, &merr)
+			assert.Len(t, merr.Unwrap(), test.failures)
+			previous := start
+			for i, attemptErr := range merr.Unwrap() {
+				var attempt *AttemptError
+				assert.ErrorAs(t, attemptErr, &attempt)
+				assert.Equal(t, i+1, attempt.Attempt)
+				assert.False(t, attempt.Time.Before(previous))
+				previous = attempt.Time
+			}
+			assert.ErrorIs(t, merr.Unwrap()[test.failures-1], test.last)
+		})
+	}
+}
+
+func TestRetryCancellation(t *testing.T) {
+	errTemporary := errors.New("temporary")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	err := Retry(ctx, RetryPolicy{MaxAttempts: -1, Backoff: ConstantBackoff(5 * time.Millisecond)}, func(context.Context) error {
+		attempts++
+		return errTemporary
+	})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorIs(t, err, errTemporary)
+	assert.Len(t, err.(*MultiError).Unwrap(), attempts+1)
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Retry(cancelled, RetryPolicy{}, func(context.Context) error {
+		t.Fatal("operation must not run with a cancelled context")
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const maxStackDepth = 32
+
+type Field struct {
+	Key   string
+	Value any
+}
+
+// TracedError annotates an error with the stack of the Wrap call and key/value fields.
+type TracedError struct {
+	err    error
+	fields []Field
+	stack  []uintptr
+}
+
+// Wrap returns nil for a nil error.
+func Wrap(err error, fields ...Field) error {
+	if err == nil {
+		return nil
+	}
+	stack := make([]uintptr, maxStackDepth)
+	stack = stack[:runtime.Callers(2, stack)]
+	return &TracedError{err: err, fields: fields, stack: stack}
+}
+
+func (e *TracedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *TracedError) Unwrap() error {
+	return e.err
+}
+
+func (e *TracedError) Fields() []Field {
+	return e.fields
+}
+
+func (e *TracedError) StackTrace() []runtime.Frame {
+	frames := runtime.CallersFrames(e.stack)
+	result := make([]runtime.Frame, 0, len(e.stack))
+	for {
+		frame, more := frames.Next()
+		result = append(result, frame)
+		if !more {
+			return result
+		}
+	}
+}
+
+// Format prints the fields of the whole chain and the stack of the innermost Wrap for %+v, the message otherwise.
+func (e *TracedError) Format(s fmt.State, verb rune) {
+	if verb != 'v' || !s.Flag('+') {
+		formatMessage(s, verb, e.Error())
+		return
+	}
+	writeDetailed(s, e)
+}
+
+// Format renders every aggregated error like %+v of TracedError, so their fields and stacks survive Append.
+func (e *MultiError) Format(s fmt.State, verb rune) {
+	if verb != 'v' || !s.Flag('+') || e == nil || len(e.errors) == 0 {
+		formatMessage(s, verb, e.Error())
+		return
+	}
+	fmt.Fprintf(s, "%d errors occured:", len(e.errors))
+	for _, err := range e.errors {
+		detailed := strings.Builder{}
+		writeDetailed(&detailed, err)
+		fmt.Fprintf(s, "\n\t* %s", strings.ReplaceAll(detailed.String(), "\n", "\n\t"))
+	}
+	io.WriteString(s, "\n")
+}
+
+func writeDetailed(w io.Writer, err error) {
+	io.WriteString(w, err.Error())
+	for _, field := range ErrorFields(err) {
+		fmt.Fprintf(w, " %s=%v", field.Key, field.Value)
+	}
+	if origin := innermostTraced(err); origin != nil {
+		for _, frame := range origin.StackTrace() {
+			fmt.Fprintf(w, "\n\t%s\n\t\t%s:%d", frame.Function, frame.File, frame.Line)
+		}
+	}
+}
+
+func innermostTraced(err error) *TracedError {
+	var origin *TracedError
+	for err != nil {
+		if traced, ok := err.(*TracedError); ok {
+			origin = traced
+		}
+		unwrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = unwrapper.Unwrap()
+	}
+	return origin
+}
+
+func formatMessage(s fmt.State, verb rune, message string) {
+	if verb == 'q' {
+		fmt.Fprintf(s, "%q", message)
+		return
+	}
+	io.WriteString(s, message)
+}
+
+// ErrorFields collects fields from the whole chain, outer errors and MultiError items first.
+func ErrorFields(err error) []Field {
+	var fields []Field
+	var walk func(err error)
+	walk = func(err error) {
+		if err == nil {
+			return
+		}
+		if traced, ok := err.(*TracedError); ok {
+			fields = append(fields, traced.fields...)
+		}
+		switch unwrapper := err.(type) {
+		case interface{ Unwrap() error }:
+			walk(unwrapper.Unwrap())
+		case interface{ Unwrap() []error }:
+			for _, inner := range unwrapper.Unwrap() {
+				walk(inner)
+			}
+		}
+	}
+	walk(err)
+	return fields
+}
+
+func TestWrap(t *testing.T) {
+	assert.NoError(t, Wrap(nil, Field{Key: "request_id", Value: 1}))
+
+	err := Wrap(io.EOF, Field{Key: "request_id", Value: "abc"}, Field{Key: "user_id", Value: 42})
+	assert.EqualError(t, err, "EOF")
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "EOF", fmt.Sprintf("%v", err))
+	assert.Equal(t, `"EOF"`, fmt.Sprintf("%q", err))
+
+	var traced *TracedError
+	assert.ErrorAs(t, err, &traced)
+	assert.Contains(t, traced.StackTrace()[0].Function, "TestWrap")
+
+	detailed := fmt.Sprintf("%+v", err)
+	assert.True(t, strings.HasPrefix(detailed, "EOF request_id=abc user_id=42\n\t"))
+	assert.Contains(t, detailed, "trace_test.go:")
+}
+
+func TestErrorFields(t *testing.T) {
+	first := Wrap(errors.New("error 1"), Field{Key: "request_id", Value: "r1"})
+	second := fmt.Errorf("handler: %w", Wrap(errors.New("error 2"), Field{Key: "user_id", Value: 7}))
+	err := Append(first, second, errors.New("error 3"))
+	outer := Wrap(err, Field{Key: "service", Value: "api"})
+
+	assert.Equal(t, []Field{
+		{Key: "service", Value: "api"},
+		{Key: "request_id", Value: "r1"},
+		{Key: "user_id", Value: 7},
+	}, ErrorFields(outer))
+	assert.Empty(t, ErrorFields(io.EOF))
+
+	assert.Equal(t, "3 errors occured:\n\t* error 1\t* handler: error 2\t* error 3\n", fmt.Sprintf("%v", err))
+
+	detailed := fmt.Sprintf("%+v", err)
+	assert.True(t, strings.HasPrefix(detailed, "3 errors occured:\n\t* error 1 request_id=r1\n\t\t"))
+	assert.Contains(t, detailed, "\n\t* handler: error 2 user_id=7\n\t\t")
+	assert.True(t, strings.HasSuffix(detailed, "\n\t* error 3\n"))
+	assert.Equal(t, 2, strings.Count(detailed, "TestErrorFields"))
+}
+
+func TestWrapKeepsInnermostStack(t *testing.T) {
+	inner := Wrap(io.EOF, Field{Key: "step", Value: "read"})
+	outer := Wrap(fmt.Errorf("load: %w", inner), Field{Key: "step", Value: "load"})
+
+	detailed := fmt.Sprintf("%+v", outer)
+	assert.True(t, strings.HasPrefix(detailed, "load: EOF step=load step=read\n\t"))
+	assert.Equal(t, 1, strings.Count(detailed, "TestWrapKeepsInnermostStack"))
+}
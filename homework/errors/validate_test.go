@@ -0,0 +1,401 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ErrUnknownRule      = errors.New("unknown validation rule")
+	ErrInvalidRuleParam = errors.New("invalid validation rule parameter")
+)
+
+// ValidationRule checks a single value, param is the text after '=' in the tag.
+type ValidationRule func(value reflect.Value, param string) error
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]ValidationRule{
+		"required": requiredRule,
+		"min":      minRule,
+		"max":      maxRule,
+		"regex":    regexRule,
+	}
+	regexps sync.Map // pattern -> *regexp.Regexp
+)
+
+func RegisterRule(name string, rule ValidationRule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = rule
+}
+
+func lookupRule(name string) (ValidationRule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rule, ok := rules[name]
+	return rule, ok
+}
+
+type ValidationError struct {
+	Path string
+	Rule string
+	Err  error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ValidationError) Code() string {
+	return e.Rule
+}
+
+func (e *ValidationError) Field() string {
+	return e.Path
+}
+
+type tagRule struct {
+	name  string
+	param string
+}
+
+// parseValidateTag splits the tag into rules applied to the field and, after "dive", to its elements.
+// A regex consumes the rest of the tag, so the pattern may contain commas.
+func parseValidateTag(tag string) (fieldRules, elemRules []tagRule) {
+	current := &fieldRules
+	for tag != "" {
+		part := tag
+		if strings.HasPrefix(tag, "regex=") {
+			tag = ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			part, tag = tag[:i], tag[i+1:]
+		} else {
+			tag = ""
+		}
+		if part == "dive" {
+			current = &elemRules
+			continue
+		}
+		name, param, _ := strings.Cut(part, "=")
+		*current = append(*current, tagRule{name: name, param: param})
+	}
+	return fieldRules, elemRules
+}
+
+// Validate checks every `validate` tag of data, walking nested structs, pointers, slices and arrays.
+// It returns nil or a MultiError with a ValidationError for every failed rule.
+func Validate(data any) error {
+	v := reflect.ValueOf(data)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validate: expected struct, got %T", data)
+	}
+	var merr *MultiError
+	validateValue(&merr, v, "", make(map[visitKey]struct{}))
+	return merr.ErrorOrNil()
+}
+
+// visitKey identifies a pointer or slice being walked, so reference cycles are entered only once.
+type visitKey struct {
+	ptr  unsafe.Pointer
+	len  int
+	kind reflect.Type
+}
+
+// enter marks v as being walked and reports false if it already is, i.e. v closes a cycle.
+func enter(visiting map[visitKey]struct{}, v reflect.Value) (visitKey, bool) {
+	key := visitKey{ptr: v.UnsafePointer(), kind: v.Type()}
+	if v.Kind() == reflect.Slice {
+		key.len = v.Len()
+	}
+	if _, ok := visiting[key]; ok {
+		return key, false
+	}
+	visiting[key] = struct{}{}
+	return key, true
+}
+
+func validateValue(merr **MultiError, v reflect.Value, path string, visiting map[visitKey]struct{}) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return
+		}
+		key, ok := enter(visiting, v)
+		if !ok {
+			return
+		}
+		defer delete(visiting, key)
+		validateValue(merr, v.Elem(), path, visiting)
+	case reflect.Interface:
+		if !v.IsNil() {
+			validateValue(merr, v.Elem(), path, visiting)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return
+		}
+		key, ok := enter(visiting, v)
+		if !ok {
+			return
+		}
+		defer delete(visiting, key)
+		for i := range v.Len() {
+			validateValue(merr, v.Index(i), fmt.Sprintf("%s[%d]", path, i), visiting)
+		}
+	case reflect.Array:
+		for i := range v.Len() {
+			validateValue(merr, v.Index(i), fmt.Sprintf("%s[%d]", path, i), visiting)
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fieldPath := field.Name
+			if path != "" {
+				fieldPath = path + "." + field.Name
+			}
+			fieldRules, elemRules := parseValidateTag(field.Tag.Get("validate"))
+			applyRules(merr, v.Field(i), fieldPath, fieldRules)
+			if len(elemRules) > 0 {
+				elems := reflect.Indirect(v.Field(i))
+				if elems.Kind() == reflect.Slice || elems.Kind() == reflect.Array {
+					for j := range elems.Len() {
+						applyRules(merr, elems.Index(j), fmt.Sprintf("%s[%d]", fieldPath, j), elemRules)
+					}
+				}
+			}
+			validateValue(merr, v.Field(i), fieldPath, visiting)
+		}
+	}
+}
+
+func applyRules(merr **MultiError, v reflect.Value, path string, tagRules []tagRule) {
+	for _, tagRule := range tagRules {
+		rule, ok := lookupRule(tagRule.name)
+		if !ok {
+			*merr = Append(*merr, &ValidationError{Path: path, Rule: tagRule.name, Err: fmt.Errorf("%w %q", ErrUnknownRule, tagRule.name)})
+			continue
+		}
+		value := v
+		if tagRule.name != "required" {
+			// only required looks at the pointer itself, other rules skip nil pointers
+			for value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					break
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Pointer {
+				continue
+			}
+		}
+		if err := rule(value, tagRule.param); err != nil {
+			*merr = Append(*merr, &ValidationError{Path: path, Rule: tagRule.name, Err: err})
+		}
+	}
+}
+
+func requiredRule(value reflect.Value, _ string) error {
+	if value.IsZero() {
+		return errors.New("is required")
+	}
+	return nil
+}
+
+// measure returns the number compared by min and max: the value of numbers, the length of everything else.
+func measure(value reflect.Value) (float64, string, error) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), "", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), "", nil
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), "", nil
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), "length ", nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), "length ", nil
+	default:
+		return 0, "", fmt.Errorf("%w: %s has no size", ErrInvalidRuleParam, value.Type())
+	}
+}
+
+func compareRule(value reflect.Value, param string, fails func(actual, limit float64) bool, relation string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("%w %q", ErrInvalidRuleParam, param)
+	}
+	actual, subject, err := measure(value)
+	if err != nil {
+		return err
+	}
+	if fails(actual, limit) {
+		return fmt.Errorf("%smust be %s %s", subject, relation, param)
+	}
+	return nil
+}
+
+func minRule(value reflect.Value, param string) error {
+	return compareRule(value, param, func(actual, limit float64) bool { return actual < limit }, "at least")
+}
+
+func maxRule(value reflect.Value, param string) error {
+	return compareRule(value, param, func(actual, limit float64) bool { return actual > limit }, "at most")
+}
+
+func regexRule(value reflect.Value, param string) error {
+	if value.Kind() != reflect.String {
+		return fmt.Errorf("%w: regex needs a string, got %s", ErrInvalidRuleParam, value.Type())
+	}
+	cached, ok := regexps.Load(param)
+	if !ok {
+		compiled, err := regexp.Compile(param)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRuleParam, err)
+		}
+		cached, _ = regexps.LoadOrStore(param, compiled)
+	}
+	if !cached.(*regexp.Regexp).MatchString(value.String()) {
+		return fmt.Errorf("must match %s", param)
+	}
+	return nil
+}
+
+type validatedAddress struct {
+	City string `validate:"required"`
+	Zip  string `validate:"regex=^[0-9]{6}$"`
+}
+
+type validatedCustomer struct {
+	Name      string             `validate:"required,max=10"`
+	Age       int                `validate:"min=18,max=120"`
+	Phone     *string            `validate:"required,regex=^\\+7\\([0-9]{3}\\)[0-9]{7}$"`
+	AltPhones []string           `validate:"max=2,dive,regex=^\\+7\\([0-9]{3}\\)[0-9]{7}$"`
+	Address   validatedAddress   `validate:"required"`
+	Previous  []validatedAddress `validate:"max=5"`
+	Nickname  *string            `validate:"min=3"`
+	internal  string             `validate:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	phone := "+7(999)1234567"
+	valid := validatedCustomer{
+		Name:      "John Doe",
+		Age:       30,
+		Phone:     &phone,
+		AltPhones: []string{"+7(000)1234567"},
+		Address:   validatedAddress{City: "Paris", Zip: "123456"},
+	}
+	assert.NoError(t, Validate(valid))
+	assert.NoError(t, Validate(&valid))
+
+	invalid := validatedCustomer{
+		Name:      "John Jacob Jingleheimer",
+		Age:       12,
+		AltPhones: []string{"+7(000)1234567", "12345", "+7(000)7654321"},
+		Previous:  []validatedAddress{{City: "London", Zip: "123456"}, {Zip: "A1B"}},
+	}
+	err := Validate(invalid)
+
+	var merr *MultiError
+	assert.ErrorAs(t, err, &merr)
+	var messages []string
+	for _, err := range merr.Unwrap() {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"Name: length must be at most 10",
+		"Age: must be at least 18",
+		"Phone: is required",
+		"AltPhones: length must be at most 2",
+		`AltPhones[1]: must match ^\+7\([0-9]{3}\)[0-9]{7}$`,
+		"Address: is required",
+		"Address.City: is required",
+		"Address.Zip: must match ^[0-9]{6}$",
+		"Previous[1].City: is required",
+		"Previous[1].Zip: must match ^[0-9]{6}$",
+	}, messages)
+
+	var fieldErr FieldError
+	assert.ErrorAs(t, merr.Unwrap()[4], &fieldErr)
+	assert.Equal(t, "AltPhones[1]", fieldErr.Field())
+	var codedErr CodedError
+	assert.ErrorAs(t, merr.Unwrap()[4], &codedErr)
+	assert.Equal(t, "regex", codedErr.Code())
+
+	assert.Error(t, Validate(42))
+}
+
+func TestValidateCustomRule(t *testing.T) {
+	RegisterRule("even", func(value reflect.Value, _ string) error {
+		if value.Kind() != reflect.Int || value.Int()%2 != 0 {
+			return errors.New("must be even")
+		}
+		return nil
+	})
+
+	type order struct {
+		Quantity int    `validate:"even,min=2"`
+		Items    []int  `validate:"dive,even"`
+		Comment  string `validate:"unknown"`
+		Code     string `validate:"max=abc"`
+	}
+
+	err := Validate(order{Quantity: 3, Items: []int{2, 5}})
+	assert.ErrorIs(t, err, ErrUnknownRule)
+	assert.ErrorIs(t, err, ErrInvalidRuleParam)
+	assert.EqualError(t, err.(*MultiError).WithFormatter(SingleLineFormatter),
+		`Quantity: must be even; Items[1]: must be even; Comment: unknown validation rule "unknown"; Code: invalid validation rule parameter "abc"`)
+}
+
+func TestValidateCycles(t *testing.T) {
+	type node struct {
+		Name     string `validate:"required"`
+		Next     *node
+		Children []node
+	}
+
+	first := &node{Name: "first"}
+	second := &node{Next: first}
+	first.Next = second
+	assert.EqualError(t, Validate(first).(*MultiError).WithFormatter(SingleLineFormatter), "Next.Name: is required")
+
+	siblings := make([]node, 2)
+	siblings[0].Name = "root"
+	siblings[0].Children = siblings
+	err := Validate(&siblings[0])
+	assert.EqualError(t, err.(*MultiError).WithFormatter(SingleLineFormatter), "Children[1].Name: is required")
+
+	shared := &node{}
+	assert.Len(t, Validate(node{Name: "dag", Children: []node{{Name: "a", Next: shared}, {Name: "b", Next: shared}}}).(*MultiError).Unwrap(), 2)
+}
+
+func TestParseValidateTag(t *testing.T) {
+	fieldRules, elemRules := parseValidateTag("required,min=1,dive,max=3,regex=^a,b$")
+	assert.Equal(t, []tagRule{{name: "required"}, {name: "min", param: "1"}}, fieldRules)
+	assert.Equal(t, []tagRule{{name: "max", param: "3"}, {name: "regex", param: "^a,b$"}}, elemRules)
+
+	fieldRules, elemRules = parseValidateTag("")
+	assert.Empty(t, fieldRules)
+	assert.Empty(t, elemRules)
+}
@@ -1,144 +1,361 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"math/rand/v2"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 )
 
-type Option func(*GamePerson)
+type Option func(*GamePerson) error
 
-func WithName(name string) func(*GamePerson) {
-	return func(person *GamePerson) {
-		for i := range name {
-			person.name[i] = name[i]
+var (
+	ErrOutOfRange   = errors.New("out of range")
+	ErrNameTooLong  = errors.New("name is too long")
+	ErrInvalidName  = errors.New("name is not valid UTF-8")
+	ErrInternedName = errors.New("interned name needs its name table")
+	ErrInvalidType  = errors.New("invalid person type")
+)
+
+// WithName rejects names that are not valid UTF-8, JSON would silently replace their bytes.
+func WithName(name string) Option {
+	return func(person *GamePerson) error {
+		if len(name) > len(person.name) {
+			return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrNameTooLong, len(name), len(person.name))
+		}
+		if !utf8.ValidString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
 		}
+		clear(person.name[:])
+		copy(person.name[:], name)
+		return person.set(nameLengthField, len(name))
 	}
 }
 
-func WithCoordinates(x, y, z int) func(*GamePerson) {
-	return func(person *GamePerson) {
+// WithTruncatedName cuts a long name to the inline size without splitting a UTF-8 sequence.
+func WithTruncatedName(name string) Option {
+	return WithName(truncateUTF8(name, gamePersonNameSize))
+}
+
+func truncateUTF8(s string, size int) string {
+	if len(s) <= size {
+		return s
+	}
+	for size > 0 && !utf8.RuneStart(s[size]) {
+		size--
+	}
+	return s[:size]
+}
+
+func WithCoordinates(x, y, z int) Option {
+	return func(person *GamePerson) error {
+		var errs []error
+		for _, coordinate := range []struct {
+			name  string
+			value int
+		}{{"x", x}, {"y", y}, {"z", z}} {
+			if coordinate.value < math.MinInt32 || coordinate.value > math.MaxInt32 {
+				errs = append(errs, fmt.Errorf("%s %d is %w [%d, %d]",
+					coordinate.name, coordinate.value, ErrOutOfRange, math.MinInt32, math.MaxInt32))
+			}
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
 		person.x = int32(x)
 		person.y = int32(y)
 		person.z = int32(z)
+		return nil
 	}
 }
 
-func WithGold(gold int) func(*GamePerson) {
-	return func(person *GamePerson) {
+func WithGold(gold int) Option {
+	return func(person *GamePerson) error {
+		if gold < 0 || gold > math.MaxUint32 {
+			return fmt.Errorf("gold %d is %w [0, %d]", gold, ErrOutOfRange, uint32(math.MaxUint32))
+		}
 		person.gold = uint32(gold)
+		return nil
 	}
 }
 
-func WithMana(mana int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		higherBits := mana & (oneByteMask << fourBitsShift) >> fourBitsShift
-		lowerBits := (mana & fourBitsMask) << fourBitsShift
-		person.manaHealth[1] |= uint8(lowerBits)
-		person.manaHealth[2] |= uint8(higherBits)
+func withField(field bitField, value int) Option {
+	return func(person *GamePerson) error {
+		return person.set(field, value)
 	}
 }
 
-func WithHealth(health int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		higherBits := (health & (fourBitsMask << oneByteShift)) >> oneByteShift
-		lowerBits := health & oneByteMask
-		person.manaHealth[1] |= uint8(higherBits)
-		person.manaHealth[0] |= uint8(lowerBits)
-	}
+func WithMana(mana int) Option {
+	return withField(manaField, mana)
 }
 
-func WithRespect(respect int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.respectExperience |= uint8(respect << fourBitsShift)
-	}
+func WithHealth(health int) Option {
+	return withField(healthField, health)
 }
 
-func WithStrength(strength int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.strengthLevel |= uint8(strength << fourBitsShift)
-	}
+func WithRespect(respect int) Option {
+	return withField(respectField, respect)
 }
 
-func WithExperience(experience int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.respectExperience |= uint8(experience)
-	}
+func WithStrength(strength int) Option {
+	return withField(strengthField, strength)
 }
 
-func WithLevel(level int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.strengthLevel |= uint8(level)
-	}
+func WithExperience(experience int) Option {
+	return withField(experienceField, experience)
 }
 
-func WithHouse() func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.typePropertyMask |= hasHouse
-	}
+func WithLevel(level int) Option {
+	return withField(levelField, level)
 }
 
-func WithGun() func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.typePropertyMask |= hasGun
-	}
+func WithHouse() Option {
+	return withField(hasHouseField, 1)
 }
 
-func WithFamily() func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.typePropertyMask |= hasFamily
-	}
+func WithGun() Option {
+	return withField(hasGunField, 1)
+}
+
+func WithFamily() Option {
+	return withField(hasFamilyField, 1)
 }
 
-func WithType(personType int) func(*GamePerson) {
-	return func(person *GamePerson) {
-		person.typePropertyMask |= 0b0000 << personType
+func WithType(personType GamePersonType) Option {
+	return func(person *GamePerson) error {
+		if !personType.valid() {
+			return fmt.Errorf("%w: %d", ErrInvalidType, personType)
+		}
+		return person.set(typeField, int(personType))
 	}
 }
 
+// GamePersonType is stored as a plain number in the type bits, so a new class only
+// needs a constant and a name in gamePersonTypeNames while it fits into the field.
+type GamePersonType int
+
 const (
-	BuilderGamePersonType = iota
+	BuilderGamePersonType GamePersonType = iota
 	BlacksmithGamePersonType
 	WarriorGamePersonType
+	MageGamePersonType
+	MerchantGamePersonType
 )
 
+var gamePersonTypeNames = []string{
+	BuilderGamePersonType:    "Builder",
+	BlacksmithGamePersonType: "Blacksmith",
+	WarriorGamePersonType:    "Warrior",
+	MageGamePersonType:       "Mage",
+	MerchantGamePersonType:   "Merchant",
+}
+
+func (t GamePersonType) valid() bool {
+	return t >= 0 && int(t) < len(gamePersonTypeNames)
+}
+
+func (t GamePersonType) String() string {
+	if !t.valid() {
+		return "GamePersonType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return gamePersonTypeNames[t]
+}
+
+func ParseGamePersonType(name string) (GamePersonType, error) {
+	for personType, typeName := range gamePersonTypeNames {
+		if typeName == name {
+			return GamePersonType(personType), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrInvalidType, name)
+}
+
+// gamePersonSchema declares the packed attributes of GamePerson, fields are laid out
+// from the lowest bit of GamePerson.stats in declaration order. A new attribute
+// needs a line here and accessors built on get/set, no masks or shifts.
+type gamePersonSchema struct {
+	Health     uint16 `bits:"10" max:"1000"`
+	Mana       uint16 `bits:"10" max:"1000"`
+	Experience uint8  `bits:"4" max:"10"`
+	Respect    uint8  `bits:"4" max:"10"`
+	Level      uint8  `bits:"4" max:"10"`
+	Strength   uint8  `bits:"4" max:"10"`
+	Type       uint8  `bits:"3"` // up to 8 classes
+	HasHouse   bool   `bits:"1"`
+	HasGun     bool   `bits:"1"`
+	HasFamily  bool   `bits:"1"`
+	NameLength uint8  `bits:"6"` // 0..gamePersonNameSize or internedNameLength
+}
+
 const (
-	fourBitsMask  = 0xF
-	oneByteMask   = 0xFF
-	fourBitsShift = 4
-	oneByteShift  = 8
-
-	typeBuilder    = 1  // 0000 0001
-	typeBlacksmith = 2  // 0000 0010
-	typeWarrior    = 4  // 0000 0100
-	hasHouse       = 8  // 0000 1000
-	hasGun         = 16 // 0001 0000
-	hasFamily      = 32 // 0010 0000
+	gamePersonStatsSize = 6
+	gamePersonNameSize  = 42
 )
 
+var gamePersonFields = parseBitSchema(gamePersonSchema{}, gamePersonStatsSize)
+
+var (
+	healthField     = gamePersonFields.field("Health")
+	manaField       = gamePersonFields.field("Mana")
+	experienceField = gamePersonFields.field("Experience")
+	respectField    = gamePersonFields.field("Respect")
+	levelField      = gamePersonFields.field("Level")
+	strengthField   = gamePersonFields.field("Strength")
+	typeField       = gamePersonFields.field("Type")
+	hasHouseField   = gamePersonFields.field("HasHouse")
+	hasGunField     = gamePersonFields.field("HasGun")
+	hasFamilyField  = gamePersonFields.field("HasFamily")
+	nameLengthField = gamePersonFields.field("NameLength")
+)
+
+type bitField struct {
+	name   string
+	offset uint
+	width  uint
+	max    uint64
+}
+
+func (f bitField) mask() uint64 {
+	return 1<<f.width - 1
+}
+
+// bitSchema keeps the fields in declaration order, so walking it is deterministic.
+type bitSchema []bitField
+
+func (s bitSchema) field(name string) bitField {
+	for _, field := range s {
+		if field.name == name {
+			return field
+		}
+	}
+	panic("bit schema: unknown field " + name)
+}
+
+func parseBitSchema(schema any, size int) bitSchema {
+	t := reflect.TypeOf(schema)
+	fields := make(bitSchema, 0, t.NumField())
+	var offset uint
+	for i := range t.NumField() {
+		structField := t.Field(i)
+		width, err := strconv.ParseUint(structField.Tag.Get("bits"), 10, 8)
+		if err != nil || width == 0 {
+			panic(fmt.Sprintf("bit schema %s.%s: invalid bits tag", t.Name(), structField.Name))
+		}
+		field := bitField{name: structField.Name, offset: offset, width: uint(width)}
+		field.max = field.mask()
+		if tag, ok := structField.Tag.Lookup("max"); ok {
+			field.max, err = strconv.ParseUint(tag, 10, 64)
+			if err != nil || field.max > field.mask() {
+				panic(fmt.Sprintf("bit schema %s.%s: max %q does not fit into %d bits", t.Name(), structField.Name, tag, width))
+			}
+		}
+		fields = append(fields, field)
+		offset += field.width
+	}
+	if offset > uint(size)*8 {
+		panic(fmt.Sprintf("bit schema %s: %d bits do not fit into %d bytes", t.Name(), offset, size))
+	}
+	return fields
+}
+
 type GamePerson struct {
-	x, y, z           int32
-	gold              uint32
-	manaHealth        [3]uint8 // byte2:[********] byte1: [****xxxx] byte0: [xxxxxxxx] -> x health, * mana
-	respectExperience uint8
-	strengthLevel     uint8
-	name              [42]byte
-	typePropertyMask  uint8
+	x, y, z int32
+	gold    uint32
+	stats   [gamePersonStatsSize]byte // little-endian bit fields described by gamePersonSchema
+	name    [gamePersonNameSize]byte  // the first NameLength bytes, or an interned name, see NameTable
 }
 
-func NewGamePerson(options ...Option) GamePerson {
+func (p *GamePerson) loadStats() uint64 {
+	var stats uint64
+	for i := range p.stats {
+		stats |= uint64(p.stats[i]) << (8 * i)
+	}
+	return stats
+}
+
+func (p *GamePerson) storeStats(stats uint64) {
+	for i := range p.stats {
+		p.stats[i] = uint8(stats >> (8 * i))
+	}
+}
+
+func (p *GamePerson) get(field bitField) uint64 {
+	return p.loadStats() >> field.offset & field.mask()
+}
+
+// set clears the field before writing, so it is safe to call repeatedly.
+func (p *GamePerson) set(field bitField, value int) error {
+	if value < 0 || uint64(value) > field.max {
+		return fmt.Errorf("%s %d is %w [0, %d]", strings.ToLower(field.name), value, ErrOutOfRange, field.max)
+	}
+	stats := p.loadStats() &^ (field.mask() << field.offset)
+	p.storeStats(stats | uint64(value)<<field.offset)
+	return nil
+}
+
+// NewGamePerson applies every option and reports all violations at once.
+func NewGamePerson(options ...Option) (GamePerson, error) {
 	gp := GamePerson{}
+	if err := gp.Apply(options...); err != nil {
+		return GamePerson{}, err
+	}
+	return gp, nil
+}
+
+// Apply updates the person with every option, on any violation it reports all of them
+// and leaves the person unchanged.
+func (p *GamePerson) Apply(options ...Option) error {
+	updated := *p
+	var errs []error
 	for _, option := range options {
-		option(&gp)
+		if err := option(&updated); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	*p = updated
+	return nil
+}
+
+// An interned person stores internedNameLength as its name length, the table id
+// in the first bytes of the name and a NUL-padded prefix of the name after it.
+const (
+	internedNameLength = 1<<6 - 1
+	internedNamePrefix = 4
+)
+
+func (p *GamePerson) HasInternedName() bool {
+	return p.get(nameLengthField) == internedNameLength
+}
+
+func (p *GamePerson) internedPrefix() string {
+	prefix := p.name[internedNamePrefix:]
+	length := bytes.IndexByte(prefix, 0)
+	if length < 0 {
+		length = len(prefix)
 	}
-	return gp
+	return string(prefix[:length])
 }
 
+// Name returns a copy of the inline name, for an interned name it is the prefix kept next to the id,
+// see NameTable.Name.
 func (p *GamePerson) Name() string {
-	return unsafe.String(&p.name[0], len(p.name))
+	if p.HasInternedName() {
+		return p.internedPrefix()
+	}
+	return string(p.name[:p.get(nameLengthField)])
 }
 
 func (p *GamePerson) X() int {
@@ -158,52 +375,43 @@ func (p *GamePerson) Gold() int {
 }
 
 func (p *GamePerson) Mana() int {
-	return int(p.manaHealth[2])<<fourBitsShift + int(p.manaHealth[1])>>fourBitsShift
+	return int(p.get(manaField))
 }
 
 func (p *GamePerson) Health() int {
-	return int(p.manaHealth[1]&fourBitsMask)<<oneByteShift + int(p.manaHealth[0])
+	return int(p.get(healthField))
 }
 
 func (p *GamePerson) Respect() int {
-	return int((p.respectExperience & (fourBitsMask << fourBitsShift)) >> fourBitsShift)
+	return int(p.get(respectField))
 }
 
 func (p *GamePerson) Strength() int {
-	return int((p.strengthLevel & (fourBitsMask << fourBitsShift)) >> fourBitsShift)
+	return int(p.get(strengthField))
 }
 
 func (p *GamePerson) Experience() int {
-	return int(p.respectExperience & fourBitsMask)
+	return int(p.get(experienceField))
 }
 
 func (p *GamePerson) Level() int {
-	return int(p.strengthLevel & fourBitsMask)
+	return int(p.get(levelField))
 }
 
 func (p *GamePerson) HasHouse() bool {
-	return p.typePropertyMask&hasHouse != 0
+	return p.get(hasHouseField) != 0
 }
 
 func (p *GamePerson) HasGun() bool {
-	return p.typePropertyMask&hasGun != 0
+	return p.get(hasGunField) != 0
 }
 
 func (p *GamePerson) HasFamily() bool {
-	return p.typePropertyMask&hasFamily != 0
+	return p.get(hasFamilyField) != 0
 }
 
-func (p *GamePerson) Type() int {
-	if p.typePropertyMask&typeBuilder != 0 {
-		return BuilderGamePersonType
-	}
-	if p.typePropertyMask&typeBlacksmith != 0 {
-		return BlacksmithGamePersonType
-	}
-	if p.typePropertyMask&typeWarrior != 0 {
-		return WarriorGamePersonType
-	}
-	return 0
+func (p *GamePerson) Type() GamePersonType {
+	return GamePersonType(p.get(typeField))
 }
 
 type GamePersonForMarshal struct {
@@ -212,6 +420,7 @@ type GamePersonForMarshal struct {
 	Z          int    `json:"z"`
 	Name       string `json:"name"`
 	Gold       int    `json:"gold"`
+	Mana       int    `json:"mana"`
 	Health     int    `json:"health"`
 	Respect    int    `json:"respect"`
 	Strength   int    `json:"strength"`
@@ -223,13 +432,22 @@ type GamePersonForMarshal struct {
 	Type       string `json:"type"`
 }
 
+// MarshalJSON refuses interned names, JSON has no room for the table, see NameTable.MarshalPerson.
 func (p *GamePerson) MarshalJSON() ([]byte, error) {
+	if p.HasInternedName() {
+		return nil, fmt.Errorf("%w: use NameTable.MarshalPerson", ErrInternedName)
+	}
+	return p.marshalJSON(p.Name())
+}
+
+func (p *GamePerson) marshalJSON(name string) ([]byte, error) {
 	gp := GamePersonForMarshal{
 		X:          p.X(),
 		Y:          p.Y(),
 		Z:          p.Z(),
-		Name:       p.Name(),
+		Name:       name,
 		Gold:       p.Gold(),
+		Mana:       p.Mana(),
 		Health:     p.Health(),
 		Respect:    p.Respect(),
 		Strength:   p.Strength(),
@@ -238,18 +456,56 @@ func (p *GamePerson) MarshalJSON() ([]byte, error) {
 		HasHouse:   p.HasHouse(),
 		HasFamily:  p.HasFamily(),
 		HasGun:     p.HasGun(),
-	}
-	switch p.Type() {
-	case BuilderGamePersonType:
-		gp.Type = "Builder"
-	case BlacksmithGamePersonType:
-		gp.Type = "Blacksmith"
-	case WarriorGamePersonType:
-		gp.Type = "Warrior"
+		Type:       p.Type().String(),
 	}
 	return json.Marshal(gp)
 }
 
+// UnmarshalJSON validates the decoded attributes with the same options NewGamePerson uses.
+func (p *GamePerson) UnmarshalJSON(data []byte) error {
+	return p.unmarshalJSON(data, WithName)
+}
+
+func (p *GamePerson) unmarshalJSON(data []byte, withName func(string) Option) error {
+	var gp GamePersonForMarshal
+	if err := json.Unmarshal(data, &gp); err != nil {
+		return err
+	}
+	options := []Option{
+		WithCoordinates(gp.X, gp.Y, gp.Z),
+		withName(gp.Name),
+		WithGold(gp.Gold),
+		WithMana(gp.Mana),
+		WithHealth(gp.Health),
+		WithRespect(gp.Respect),
+		WithStrength(gp.Strength),
+		WithExperience(gp.Experience),
+		WithLevel(gp.Level),
+	}
+	if gp.HasHouse {
+		options = append(options, WithHouse())
+	}
+	if gp.HasFamily {
+		options = append(options, WithFamily())
+	}
+	if gp.HasGun {
+		options = append(options, WithGun())
+	}
+	personType, err := ParseGamePersonType(gp.Type)
+	if err != nil {
+		options = append(options, func(*GamePerson) error { return err })
+	} else {
+		options = append(options, WithType(personType))
+	}
+
+	person, err := NewGamePerson(options...)
+	if err != nil {
+		return err
+	}
+	*p = person
+	return nil
+}
+
 func TestGamePerson(t *testing.T) {
 	assert.LessOrEqual(t, unsafe.Sizeof(GamePerson{}), uintptr(64))
 
@@ -279,7 +535,8 @@ func TestGamePerson(t *testing.T) {
 		WithType(personType),
 	}
 
-	person := NewGamePerson(options...)
+	person, err := NewGamePerson(options...)
+	assert.NoError(t, err)
 	assert.Equal(t, name, person.Name())
 	assert.Equal(t, x, person.X())
 	assert.Equal(t, y, person.Y())
@@ -299,5 +556,250 @@ func TestGamePerson(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t,
 		string(rawData),
-		"{\"x\":-2147483648,\"y\":2147483647,\"z\":0,\"name\":\"aaaaaaaaaaaaa_bbbbbbbbbbbbb_cccccccccccccc\",\"gold\":2147483647,\"health\":1000,\"respect\":10,\"strength\":10,\"experience\":10,\"level\":10,\"has_house\":true,\"has_family\":true,\"has_gun\":false,\"type\":\"Builder\"}")
+		"{\"x\":-2147483648,\"y\":2147483647,\"z\":0,\"name\":\"aaaaaaaaaaaaa_bbbbbbbbbbbbb_cccccccccccccc\",\"gold\":2147483647,\"mana\":1000,\"health\":1000,\"respect\":10,\"strength\":10,\"experience\":10,\"level\":10,\"has_house\":true,\"has_family\":true,\"has_gun\":false,\"type\":\"Builder\"}")
+}
+
+func TestGamePersonSchema(t *testing.T) {
+	assert.Equal(t, bitField{name: "Health", offset: 0, width: 10, max: 1000}, healthField)
+	assert.Equal(t, bitField{name: "Mana", offset: 10, width: 10, max: 1000}, manaField)
+	assert.Equal(t, bitField{name: "HasFamily", offset: 41, width: 1, max: 1}, hasFamilyField)
+	assert.Equal(t, bitField{name: "NameLength", offset: 42, width: 6, max: 63}, nameLengthField)
+
+	person, err := NewGamePerson(WithHealth(1000), WithMana(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, [gamePersonStatsSize]byte{0xE8, 0xA3, 0x0F}, person.stats)
+
+	person, err = NewGamePerson(WithRespect(10), WithExperience(7), WithStrength(3), WithGun())
+	assert.NoError(t, err)
+	assert.Equal(t, 10, person.Respect())
+	assert.Equal(t, 7, person.Experience())
+	assert.Equal(t, 3, person.Strength())
+	assert.Equal(t, 0, person.Level())
+	assert.True(t, person.HasGun())
+	assert.False(t, person.HasHouse())
+	assert.False(t, person.HasFamily())
+
+	assert.EqualError(t, person.set(manaField, 1001), "mana 1001 is out of range [0, 1000]")
+	assert.NoError(t, person.set(manaField, 1000))
+	assert.NoError(t, person.set(manaField, 1))
+	assert.Equal(t, 1, person.Mana())
+	assert.Equal(t, 10, person.Respect())
+}
+
+func TestGamePersonType(t *testing.T) {
+	var person GamePerson
+	assert.Equal(t, BuilderGamePersonType, person.Type())
+
+	for personType := range GamePersonType(len(gamePersonTypeNames)) {
+		assert.NoError(t, person.Apply(WithType(personType)))
+		assert.Equal(t, personType, person.Type())
+		assert.Equal(t, uint64(personType), person.get(typeField))
+
+		parsed, err := ParseGamePersonType(personType.String())
+		assert.NoError(t, err)
+		assert.Equal(t, personType, parsed)
+	}
+
+	assert.Equal(t, "Mage", MageGamePersonType.String())
+	assert.Equal(t, "Merchant", MerchantGamePersonType.String())
+	assert.Equal(t, "GamePersonType(9)", GamePersonType(9).String())
+	assert.Equal(t, "GamePersonType(-1)", GamePersonType(-1).String())
+	assert.LessOrEqual(t, uint64(len(gamePersonTypeNames)-1), typeField.max)
+
+	_, err := ParseGamePersonType("builder")
+	assert.ErrorIs(t, err, ErrInvalidType)
+	assert.ErrorIs(t, person.Apply(WithType(-1)), ErrInvalidType)
+
+	data, err := json.Marshal(&person)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"type":"Merchant"`)
+}
+
+func TestParseBitSchema(t *testing.T) {
+	type schema struct {
+		Small uint8  `bits:"3"`
+		Large uint16 `bits:"9" max:"300"`
+		Flag  bool   `bits:"1"`
+	}
+	fields := parseBitSchema(schema{}, 2)
+	assert.Equal(t, bitSchema{
+		{name: "Small", offset: 0, width: 3, max: 7},
+		{name: "Large", offset: 3, width: 9, max: 300},
+		{name: "Flag", offset: 12, width: 1, max: 1},
+	}, fields)
+	assert.Equal(t, fields[1], fields.field("Large"))
+	assert.Panics(t, func() { fields.field("Missing") })
+
+	assert.Panics(t, func() { parseBitSchema(schema{}, 1) })
+	assert.Panics(t, func() {
+		parseBitSchema(struct {
+			Field uint8 `bits:"zero"`
+		}{}, 1)
+	})
+	assert.Panics(t, func() {
+		parseBitSchema(struct {
+			Field uint8 `bits:"2" max:"4"`
+		}{}, 1)
+	})
+}
+
+func TestGamePersonValidation(t *testing.T) {
+	tests := map[string]struct {
+		options []Option
+		errs    []error
+		message string
+	}{
+		"valid": {
+			options: []Option{WithMana(1000), WithRespect(10), WithName("Conan"), WithType(WarriorGamePersonType)},
+		},
+		"mana overflow": {
+			options: []Option{WithMana(2000)},
+			errs:    []error{ErrOutOfRange},
+			message: "mana 2000 is out of range [0, 1000]",
+		},
+		"negative health": {
+			options: []Option{WithHealth(-1)},
+			errs:    []error{ErrOutOfRange},
+			message: "health -1 is out of range [0, 1000]",
+		},
+		"long name": {
+			options: []Option{WithName(strings.Repeat("a", 60))},
+			errs:    []error{ErrNameTooLong},
+			message: "name is too long: 60 bytes, at most 42 allowed",
+		},
+		"invalid utf-8 name": {
+			options: []Option{WithName("\xff\xfe")},
+			errs:    []error{ErrInvalidName},
+			message: `name is not valid UTF-8: "\xff\xfe"`,
+		},
+		"coordinates and gold": {
+			options: []Option{WithCoordinates(math.MaxInt32+1, 0, math.MinInt32-1), WithGold(-5)},
+			errs:    []error{ErrOutOfRange},
+			message: "x 2147483648 is out of range [-2147483648, 2147483647]\n" +
+				"z -2147483649 is out of range [-2147483648, 2147483647]\n" +
+				"gold -5 is out of range [0, 4294967295]",
+		},
+		"every violation is reported": {
+			options: []Option{WithRespect(20), WithStrength(11), WithLevel(3), WithExperience(-3), WithType(7)},
+			errs:    []error{ErrOutOfRange, ErrInvalidType},
+			message: "respect 20 is out of range [0, 10]\n" +
+				"strength 11 is out of range [0, 10]\n" +
+				"experience -3 is out of range [0, 10]\n" +
+				"invalid person type: 7",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			person, err := NewGamePerson(test.options...)
+			if len(test.errs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			for _, expected := range test.errs {
+				assert.ErrorIs(t, err, expected)
+			}
+			assert.EqualError(t, err, test.message)
+			assert.Equal(t, GamePerson{}, person)
+		})
+	}
+}
+
+func randomGamePersonOptions(r *rand.Rand) []Option {
+	alphabet := []rune("abcxyzабвгд世界")
+	var name []byte
+	for {
+		next := utf8.AppendRune(name, alphabet[r.IntN(len(alphabet))])
+		if len(next) > gamePersonNameSize || r.IntN(20) == 0 {
+			break
+		}
+		name = next
+	}
+	options := []Option{
+		WithName(string(name)),
+		WithCoordinates(int(r.Int32())-r.IntN(math.MaxInt32), int(r.Int32()), -int(r.Int32())),
+		WithGold(int(r.Uint32())),
+		WithMana(r.IntN(1001)),
+		WithHealth(r.IntN(1001)),
+		WithRespect(r.IntN(11)),
+		WithStrength(r.IntN(11)),
+		WithExperience(r.IntN(11)),
+		WithLevel(r.IntN(11)),
+		WithType(GamePersonType(r.IntN(len(gamePersonTypeNames)))),
+	}
+	if r.IntN(2) == 0 {
+		options = append(options, WithHouse())
+	}
+	if r.IntN(2) == 0 {
+		options = append(options, WithGun())
+	}
+	if r.IntN(2) == 0 {
+		options = append(options, WithFamily())
+	}
+	return options
+}
+
+func TestGamePersonJSONRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewPCG(43, 45))
+	for range 1000 {
+		person, err := NewGamePerson(randomGamePersonOptions(r)...)
+		assert.NoError(t, err)
+
+		data, err := json.Marshal(&person)
+		assert.NoError(t, err)
+
+		var decoded GamePerson
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, person, decoded)
+	}
+}
+
+func TestGamePersonUnmarshalJSON(t *testing.T) {
+	const valid = `{"x":1,"y":-2,"z":3,"name":"Smith","gold":100,"mana":20,"health":30,` +
+		`"respect":4,"strength":5,"experience":6,"level":7,"has_house":true,"has_family":false,"has_gun":true,"type":"Blacksmith"}`
+
+	var person GamePerson
+	assert.NoError(t, json.Unmarshal([]byte(valid), &person))
+	assert.Equal(t, []int{1, -2, 3, 100, 20, 30, 4, 5, 6, 7}, []int{
+		person.X(), person.Y(), person.Z(), person.Gold(), person.Mana(), person.Health(),
+		person.Respect(), person.Strength(), person.Experience(), person.Level(),
+	})
+	assert.Equal(t, "Smith", person.Name())
+	assert.True(t, person.HasHouse())
+	assert.False(t, person.HasFamily())
+	assert.True(t, person.HasGun())
+	assert.Equal(t, BlacksmithGamePersonType, person.Type())
+
+	tests := map[string]struct {
+		data string
+		errs []error
+	}{
+		"unknown type": {
+			data: `{"type":"Wizard"}`,
+			errs: []error{ErrInvalidType},
+		},
+		"out of range": {
+			data: `{"type":"Warrior","mana":5000,"respect":11,"gold":-1}`,
+			errs: []error{ErrOutOfRange},
+		},
+		"long name": {
+			data: `{"type":"Builder","name":"` + strings.Repeat("n", 43) + `"}`,
+			errs: []error{ErrNameTooLong},
+		},
+		"malformed": {
+			data: `{"type":`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			decoded := person
+			err := json.Unmarshal([]byte(test.data), &decoded)
+			assert.Error(t, err)
+			for _, expected := range test.errs {
+				assert.ErrorIs(t, err, expected)
+			}
+			assert.Equal(t, person, decoded)
+		})
+	}
 }
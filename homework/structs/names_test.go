@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// NameTable keeps names too long for the inline array, ids are only meaningful
+// for the table that issued them.
+type NameTable struct {
+	mu    sync.RWMutex
+	ids   map[string]uint32
+	names []string
+}
+
+func NewNameTable() *NameTable {
+	return &NameTable{ids: make(map[string]uint32)}
+}
+
+// Intern returns the id of name, adding it on first use.
+func (t *NameTable) Intern(name string) uint32 {
+	t.mu.RLock()
+	id, ok := t.ids[name]
+	t.mu.RUnlock()
+	if ok {
+		return id
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[name]; ok {
+		return id
+	}
+	id = uint32(len(t.names))
+	t.ids[name] = id
+	t.names = append(t.names, name)
+	return id
+}
+
+func (t *NameTable) Lookup(id uint32) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if int(id) >= len(t.names) {
+		return "", false
+	}
+	return t.names[id], true
+}
+
+func (t *NameTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.names)
+}
+
+// Name returns the full name of a person, falling back to the prefix for ids of another table.
+func (t *NameTable) Name(p *GamePerson) string {
+	if !p.HasInternedName() {
+		return p.Name()
+	}
+	if name, ok := t.Lookup(binary.LittleEndian.Uint32(p.name[:internedNamePrefix])); ok {
+		return name
+	}
+	return p.internedPrefix()
+}
+
+// MarshalPerson encodes p like MarshalJSON but with the full name, so interned persons round-trip
+// through UnmarshalPerson of any table.
+func (t *NameTable) MarshalPerson(p *GamePerson) ([]byte, error) {
+	if !p.HasInternedName() {
+		return p.marshalJSON(p.Name())
+	}
+	id := binary.LittleEndian.Uint32(p.name[:internedNamePrefix])
+	name, ok := t.Lookup(id)
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d is not in this table", ErrInternedName, id)
+	}
+	return p.marshalJSON(name)
+}
+
+// UnmarshalPerson decodes p like UnmarshalJSON and interns names too long for the inline array.
+func (t *NameTable) UnmarshalPerson(data []byte, p *GamePerson) error {
+	return p.unmarshalJSON(data, func(name string) Option {
+		return WithInternedName(t, name)
+	})
+}
+
+// WithInternedName keeps names that fit inline like WithName and moves longer ones to the table.
+func WithInternedName(table *NameTable, name string) Option {
+	return func(person *GamePerson) error {
+		if len(name) <= len(person.name) {
+			return WithName(name)(person)
+		}
+		if !utf8.ValidString(name) {
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
+		}
+		clear(person.name[:])
+		binary.LittleEndian.PutUint32(person.name[:internedNamePrefix], table.Intern(name))
+		copy(person.name[internedNamePrefix:], truncateUTF8(name, len(person.name)-internedNamePrefix))
+		return person.set(nameLengthField, internedNameLength)
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	assert.Equal(t, "short", truncateUTF8("short", 42))
+	assert.Equal(t, "abc", truncateUTF8("abcdef", 3))
+	assert.Equal(t, "привет", truncateUTF8("привет мир", 12))
+	assert.Equal(t, "приве", truncateUTF8("привет мир", 11))
+	assert.Equal(t, "a", truncateUTF8("a😀", 4))
+	assert.Equal(t, "", truncateUTF8("😀", 3))
+}
+
+func TestGamePersonName(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"), WithHealth(1000))
+	assert.NoError(t, err)
+	assert.Equal(t, "Smith", person.Name())
+	assert.False(t, person.HasInternedName())
+
+	var nameless GamePerson
+	assert.Equal(t, "", nameless.Name())
+
+	name := person.Name()
+	assert.NoError(t, person.SetName("Jo"))
+	assert.Equal(t, "Jo", person.Name())
+	assert.Equal(t, "Smith", name)
+	assert.Equal(t, 1000, person.Health())
+
+	full := strings.Repeat("ж", gamePersonNameSize/2)
+	assert.NoError(t, person.SetName(full))
+	assert.Equal(t, full, person.Name())
+
+	// 43 bytes, an inline cut at 42 would split the last rune
+	long := "a" + strings.Repeat("ж", gamePersonNameSize/2)
+	assert.ErrorIs(t, person.SetName(long), ErrNameTooLong)
+	assert.NoError(t, person.Apply(WithTruncatedName(long)))
+	assert.Equal(t, long[:41], person.Name())
+	assert.True(t, strings.HasSuffix(person.Name(), "ж"))
+}
+
+func TestNameTable(t *testing.T) {
+	table := NewNameTable()
+	long := "Sir " + strings.Repeat("Lancelot ", 8) + "the Brave"
+
+	person, err := NewGamePerson(WithInternedName(table, long), WithMana(10))
+	assert.NoError(t, err)
+	assert.True(t, person.HasInternedName())
+	assert.Equal(t, long, table.Name(&person))
+	assert.Equal(t, long[:38], person.Name())
+	assert.Equal(t, 10, person.Mana())
+	assert.LessOrEqual(t, unsafe.Sizeof(person), uintptr(64))
+
+	other, err := NewGamePerson(WithInternedName(table, long))
+	assert.NoError(t, err)
+	assert.Equal(t, person.name, other.name)
+	assert.Equal(t, 1, table.Len())
+
+	short, err := NewGamePerson(WithInternedName(table, "Smith"))
+	assert.NoError(t, err)
+	assert.False(t, short.HasInternedName())
+	assert.Equal(t, "Smith", table.Name(&short))
+	assert.Equal(t, 1, table.Len())
+
+	assert.Equal(t, long[:38], NewNameTable().Name(&person))
+
+	prefix := person.Name()
+	assert.NoError(t, person.SetName("Lance"))
+	assert.Equal(t, long[:38], prefix)
+	assert.False(t, person.HasInternedName())
+	assert.Equal(t, "Lance", table.Name(&person))
+
+	name, ok := table.Lookup(0)
+	assert.True(t, ok)
+	assert.Equal(t, long, name)
+	_, ok = table.Lookup(1)
+	assert.False(t, ok)
+}
+
+func TestNameTableJSON(t *testing.T) {
+	table := NewNameTable()
+	long := "Sir " + strings.Repeat("Lancelot ", 8) + "the Brave"
+	person, err := NewGamePerson(WithInternedName(table, long), WithGold(7), WithType(MageGamePersonType))
+	assert.NoError(t, err)
+
+	_, err = json.Marshal(&person)
+	assert.ErrorIs(t, err, ErrInternedName)
+
+	data, err := table.MarshalPerson(&person)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"name":"`+long+`"`)
+
+	var decoded GamePerson
+	assert.NoError(t, table.UnmarshalPerson(data, &decoded))
+	assert.Equal(t, person, decoded)
+
+	other := NewNameTable()
+	other.Intern("somebody else entirely, long enough to be interned")
+	assert.NoError(t, other.UnmarshalPerson(data, &decoded))
+	assert.Equal(t, long, other.Name(&decoded))
+	assert.Equal(t, 7, decoded.Gold())
+
+	_, err = NewNameTable().MarshalPerson(&person)
+	assert.ErrorIs(t, err, ErrInternedName)
+	assert.ErrorIs(t, decoded.UnmarshalJSON(data), ErrNameTooLong)
+
+	short, err := NewGamePerson(WithName("Smith"))
+	assert.NoError(t, err)
+	data, err = table.MarshalPerson(&short)
+	assert.NoError(t, err)
+	plain, err := json.Marshal(&short)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, data)
+
+	_, err = NewGamePerson(WithInternedName(table, strings.Repeat("\xff", 50)))
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+func TestNameTableConcurrentIntern(t *testing.T) {
+	table := NewNameTable()
+	ids := make([]uint32, 16)
+	var wg sync.WaitGroup
+	for i := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				id := table.Intern(strings.Repeat("x", 50+j))
+				if j == 0 {
+					ids[i] = id
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, table.Len())
+	for _, id := range ids {
+		assert.Equal(t, ids[0], id)
+	}
+}
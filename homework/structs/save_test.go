@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The save format is an 8-byte header followed by fixed-size little-endian records:
+//
+//	magic "GPRS" | version uint16 | record size uint16 | record...
+//
+// Every version ever written keeps a decoder in gamePersonCodecs, so adding a field
+// means a new version with its own codec while the old decoders migrate old files.
+// Persons with interned names are refused, a table id means nothing without its NameTable.
+const (
+	gamePersonMagic      = "GPRS"
+	gamePersonHeaderSize = 8
+	gamePersonVersion    = 3
+)
+
+var (
+	ErrBadMagic           = errors.New("not a game person file")
+	ErrUnsupportedVersion = errors.New("unsupported game person format version")
+	ErrCorruptRecord      = errors.New("corrupt game person record")
+)
+
+type gamePersonCodec struct {
+	size   int
+	encode func(p *GamePerson, buf []byte)
+	decode func(buf []byte) (GamePerson, error)
+}
+
+// Only the current version needs encode.
+var gamePersonCodecs = map[uint16]gamePersonCodec{
+	1: {size: 64, decode: decodeGamePersonV1},
+	2: {size: 64, decode: decodeGamePersonV2},
+	3: {size: 64, encode: encodeGamePersonV3, decode: decodeGamePersonV3},
+}
+
+// gamePersonSchemaV2 is the stats layout of versions 1 and 2, before names tracked their length.
+type gamePersonSchemaV2 struct {
+	Health     uint16 `bits:"12" max:"1000"`
+	Mana       uint16 `bits:"12" max:"1000"`
+	Experience uint8  `bits:"4" max:"10"`
+	Respect    uint8  `bits:"4" max:"10"`
+	Level      uint8  `bits:"4" max:"10"`
+	Strength   uint8  `bits:"4" max:"10"`
+	Type       uint8  `bits:"3"`
+	HasHouse   bool   `bits:"1"`
+	HasGun     bool   `bits:"1"`
+	HasFamily  bool   `bits:"1"`
+}
+
+var gamePersonFieldsV2 = parseBitSchema(gamePersonSchemaV2{}, gamePersonStatsSize)
+
+func encodeGamePersonV3(p *GamePerson, buf []byte) {
+	binary.LittleEndian.PutUint32(buf[0:], uint32(p.x))
+	binary.LittleEndian.PutUint32(buf[4:], uint32(p.y))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(p.z))
+	binary.LittleEndian.PutUint32(buf[12:], p.gold)
+	copy(buf[16:22], p.stats[:])
+	copy(buf[22:64], p.name[:])
+}
+
+// readGamePersonRecord copies the record as is, every version so far shares the byte layout.
+func readGamePersonRecord(buf []byte) GamePerson {
+	var p GamePerson
+	p.x = int32(binary.LittleEndian.Uint32(buf[0:]))
+	p.y = int32(binary.LittleEndian.Uint32(buf[4:]))
+	p.z = int32(binary.LittleEndian.Uint32(buf[8:]))
+	p.gold = binary.LittleEndian.Uint32(buf[12:])
+	copy(p.stats[:], buf[16:22])
+	copy(p.name[:], buf[22:64])
+	return p
+}
+
+func decodeGamePersonV3(buf []byte) (GamePerson, error) {
+	p := readGamePersonRecord(buf)
+	return p, validateStats(&p)
+}
+
+// readGamePersonV2 moves every field of the old stats layout to the current one and
+// derives the name length from the NUL padding.
+func readGamePersonV2(buf []byte) (GamePerson, error) {
+	p := readGamePersonRecord(buf)
+	old := p.loadStats()
+	p.storeStats(0)
+	var errs []error
+	for _, oldField := range gamePersonFieldsV2 {
+		if err := p.set(gamePersonFields.field(oldField.name), int(old>>oldField.offset&oldField.mask())); err != nil {
+			errs = append(errs, fmt.Errorf("%w: %w", ErrCorruptRecord, err))
+		}
+	}
+	_ = p.set(nameLengthField, len(bytes.TrimRight(p.name[:], "\x00")))
+	return p, errors.Join(errs...)
+}
+
+func decodeGamePersonV2(buf []byte) (GamePerson, error) {
+	p, err := readGamePersonV2(buf)
+	if err != nil {
+		return p, err
+	}
+	return p, validateStats(&p)
+}
+
+// gamePersonV1Types maps the one-hot type bits of v1 saves. Early v1 writers left builders
+// at the zero value, later ones stored them as 0b001 like the other types.
+var gamePersonV1Types = map[uint64]GamePersonType{
+	0b000: BuilderGamePersonType,
+	0b001: BuilderGamePersonType,
+	0b010: BlacksmithGamePersonType,
+	0b100: WarriorGamePersonType,
+}
+
+// decodeGamePersonV1 also migrates the type bits of v1 to the type number.
+func decodeGamePersonV1(buf []byte) (GamePerson, error) {
+	p, err := readGamePersonV2(buf)
+	if err != nil {
+		return p, err
+	}
+	typeBits := p.get(typeField)
+	personType, ok := gamePersonV1Types[typeBits]
+	if !ok {
+		return p, fmt.Errorf("%w: v1 type bits %03b", ErrCorruptRecord, typeBits)
+	}
+	_ = p.set(typeField, int(personType))
+	return p, validateStats(&p)
+}
+
+// validateStats catches records whose bit fields exceed the schema limits, name an unknown type,
+// claim a name longer than the inline array or carry a table id.
+func validateStats(p *GamePerson) error {
+	var errs []error
+	for _, field := range gamePersonFields {
+		if value := p.get(field); value > field.max {
+			errs = append(errs, fmt.Errorf("%w: %s %d exceeds %d", ErrCorruptRecord, field.name, value, field.max))
+		}
+	}
+	if !p.Type().valid() {
+		errs = append(errs, fmt.Errorf("%w: %w %d", ErrCorruptRecord, ErrInvalidType, p.Type()))
+	}
+	if length := p.get(nameLengthField); length == internedNameLength {
+		errs = append(errs, fmt.Errorf("%w: %w", ErrCorruptRecord, ErrInternedName))
+	} else if length > gamePersonNameSize {
+		errs = append(errs, fmt.Errorf("%w: name length %d", ErrCorruptRecord, length))
+	}
+	return errors.Join(errs...)
+}
+
+func appendGamePersonHeader(buf []byte) []byte {
+	buf = append(buf, gamePersonMagic...)
+	buf = binary.LittleEndian.AppendUint16(buf, gamePersonVersion)
+	return binary.LittleEndian.AppendUint16(buf, uint16(gamePersonCodecs[gamePersonVersion].size))
+}
+
+func parseGamePersonHeader(header []byte) (uint16, gamePersonCodec, error) {
+	if string(header[:4]) != gamePersonMagic {
+		return 0, gamePersonCodec{}, fmt.Errorf("%w: magic %q", ErrBadMagic, header[:4])
+	}
+	version := binary.LittleEndian.Uint16(header[4:])
+	codec, ok := gamePersonCodecs[version]
+	if !ok {
+		return 0, gamePersonCodec{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	if size := binary.LittleEndian.Uint16(header[6:]); int(size) != codec.size {
+		return 0, gamePersonCodec{}, fmt.Errorf("%w: version %d records are %d bytes, header says %d",
+			ErrCorruptRecord, version, codec.size, size)
+	}
+	return version, codec, nil
+}
+
+// MarshalBinary encodes a single person as a file with one record.
+func (p *GamePerson) MarshalBinary() ([]byte, error) {
+	if p.HasInternedName() {
+		return nil, ErrInternedName
+	}
+	codec := gamePersonCodecs[gamePersonVersion]
+	buf := appendGamePersonHeader(make([]byte, 0, gamePersonHeaderSize+codec.size))
+	buf = buf[:gamePersonHeaderSize+codec.size]
+	codec.encode(p, buf[gamePersonHeaderSize:])
+	return buf, nil
+}
+
+func (p *GamePerson) UnmarshalBinary(data []byte) error {
+	if len(data) < gamePersonHeaderSize {
+		return io.ErrUnexpectedEOF
+	}
+	_, codec, err := parseGamePersonHeader(data)
+	if err != nil {
+		return err
+	}
+	if len(data) != gamePersonHeaderSize+codec.size {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrCorruptRecord, gamePersonHeaderSize+codec.size, len(data))
+	}
+	person, err := codec.decode(data[gamePersonHeaderSize:])
+	if err != nil {
+		return err
+	}
+	*p = person
+	return nil
+}
+
+// GamePersonWriter streams persons in the current format, call Flush when done.
+type GamePersonWriter struct {
+	w             *bufio.Writer
+	record        []byte
+	headerWritten bool
+}
+
+func NewGamePersonWriter(w io.Writer) *GamePersonWriter {
+	return &GamePersonWriter{
+		w:      bufio.NewWriterSize(w, 64<<10),
+		record: make([]byte, gamePersonCodecs[gamePersonVersion].size),
+	}
+}
+
+func (w *GamePersonWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+	_, err := w.w.Write(appendGamePersonHeader(nil))
+	return err
+}
+
+func (w *GamePersonWriter) Write(p *GamePerson) error {
+	if p.HasInternedName() {
+		return ErrInternedName
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	gamePersonCodecs[gamePersonVersion].encode(p, w.record)
+	_, err := w.w.Write(w.record)
+	return err
+}
+
+func (w *GamePersonWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// GamePersonReader reads files of any known version and migrates records to the current GamePerson.
+type GamePersonReader struct {
+	r       *bufio.Reader
+	version uint16
+	codec   gamePersonCodec
+	record  []byte
+}
+
+func NewGamePersonReader(r io.Reader) (*GamePersonReader, error) {
+	reader := &GamePersonReader{r: bufio.NewReaderSize(r, 64<<10)}
+	header := make([]byte, gamePersonHeaderSize)
+	if _, err := io.ReadFull(reader.r, header); err != nil {
+		return nil, err
+	}
+	var err error
+	reader.version, reader.codec, err = parseGamePersonHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	reader.record = make([]byte, reader.codec.size)
+	return reader, nil
+}
+
+func (r *GamePersonReader) Version() uint16 {
+	return r.version
+}
+
+// Read returns io.EOF after the last record and io.ErrUnexpectedEOF for a truncated one.
+func (r *GamePersonReader) Read() (GamePerson, error) {
+	if _, err := io.ReadFull(r.r, r.record); err != nil {
+		return GamePerson{}, err
+	}
+	return r.codec.decode(r.record)
+}
+
+func TestGamePersonBinary(t *testing.T) {
+	person, err := NewGamePerson(
+		WithName("Smith"),
+		WithCoordinates(-1, 2, 0x01020304),
+		WithGold(0xAABBCCDD),
+		WithHealth(1000),
+		WithMana(1000),
+		WithLevel(10),
+		WithGun(),
+	)
+	assert.NoError(t, err)
+
+	data, err := person.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, gamePersonHeaderSize+64, len(data))
+	assert.Equal(t, []byte{'G', 'P', 'R', 'S', 3, 0, 64, 0}, data[:8])
+	assert.Equal(t, []byte{
+		0xFF, 0xFF, 0xFF, 0xFF,
+		0x02, 0x00, 0x00, 0x00,
+		0x04, 0x03, 0x02, 0x01,
+		0xDD, 0xCC, 0xBB, 0xAA,
+		0xE8, 0xA3, 0x0F, 0xA0, 0x00, 0x15,
+		'S', 'm', 'i', 't', 'h', 0x00,
+	}, data[8:36])
+
+	// the same person as written by version 2
+	v2 := bytes.Clone(data)
+	v2[4] = 2
+	copy(v2[8+16:], []byte{0xE8, 0x83, 0x3E, 0x00, 0x0A, 0x10})
+	var migrated GamePerson
+	assert.NoError(t, migrated.UnmarshalBinary(v2))
+	assert.Equal(t, person, migrated)
+	assert.Equal(t, "Smith", migrated.Name())
+
+	var decoded GamePerson
+	assert.NoError(t, decoded.UnmarshalBinary(data))
+	assert.Equal(t, person, decoded)
+}
+
+func TestGamePersonBinaryErrors(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"))
+	assert.NoError(t, err)
+	valid, err := person.MarshalBinary()
+	assert.NoError(t, err)
+
+	corrupt := func(offset int, value byte) []byte {
+		data := bytes.Clone(valid)
+		data[offset] = value
+		return data
+	}
+
+	tests := map[string]struct {
+		data []byte
+		err  error
+	}{
+		"bad magic":            {data: corrupt(0, 'X'), err: ErrBadMagic},
+		"unknown version":      {data: corrupt(4, 99), err: ErrUnsupportedVersion},
+		"wrong record size":    {data: corrupt(6, 32), err: ErrCorruptRecord},
+		"respect out of range": {data: corrupt(8+19, 0x0F), err: ErrCorruptRecord},
+		"unknown type":         {data: corrupt(8+20, 0x70), err: ErrInvalidType},
+		"name too long":        {data: corrupt(8+21, 43<<2), err: ErrCorruptRecord},
+		"truncated":            {data: valid[:40], err: ErrCorruptRecord},
+		"no header":            {data: valid[:4], err: io.ErrUnexpectedEOF},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var decoded GamePerson
+			assert.ErrorIs(t, decoded.UnmarshalBinary(test.data), test.err)
+			assert.Equal(t, GamePerson{}, decoded)
+		})
+	}
+
+	allCorrupt := bytes.Clone(valid)
+	copy(allCorrupt[8+16:], bytes.Repeat([]byte{0xFF}, gamePersonStatsSize))
+	var decoded GamePerson
+	assert.EqualError(t, decoded.UnmarshalBinary(allCorrupt), strings.Join([]string{
+		"corrupt game person record: Health 1023 exceeds 1000",
+		"corrupt game person record: Mana 1023 exceeds 1000",
+		"corrupt game person record: Experience 15 exceeds 10",
+		"corrupt game person record: Respect 15 exceeds 10",
+		"corrupt game person record: Level 15 exceeds 10",
+		"corrupt game person record: Strength 15 exceeds 10",
+		"corrupt game person record: invalid person type 7",
+		"corrupt game person record: interned name needs its name table",
+	}, "\n"))
+}
+
+// legacyGamePersonFile encodes a person in the stats layout of versions 1 and 2.
+func legacyGamePersonFile(t *testing.T, person GamePerson, version uint16, typeBits uint64) []byte {
+	data, err := person.MarshalBinary()
+	assert.NoError(t, err)
+	binary.LittleEndian.PutUint16(data[4:], version)
+
+	var stats uint64
+	for _, field := range gamePersonFieldsV2 {
+		value := person.get(gamePersonFields.field(field.name))
+		if field.name == "Type" {
+			value = typeBits
+		}
+		stats |= value << field.offset
+	}
+	for i := range gamePersonStatsSize {
+		data[gamePersonHeaderSize+16+i] = byte(stats >> (8 * i))
+	}
+	return data
+}
+
+func TestGamePersonBinaryMigrate(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"), WithHealth(1000), WithMana(999), WithRespect(10), WithGun(), WithFamily())
+	assert.NoError(t, err)
+
+	for typeBits, expected := range map[uint64]GamePersonType{
+		0b000: BuilderGamePersonType,
+		0b001: BuilderGamePersonType,
+		0b010: BlacksmithGamePersonType,
+		0b100: WarriorGamePersonType,
+	} {
+		reader, err := NewGamePersonReader(bytes.NewReader(legacyGamePersonFile(t, person, 1, typeBits)))
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(1), reader.Version())
+		migrated, err := reader.Read()
+		assert.NoError(t, err)
+
+		expectedPerson := person
+		assert.NoError(t, expectedPerson.SetType(expected))
+		assert.Equal(t, expectedPerson, migrated)
+		assert.Equal(t, "Smith", migrated.Name())
+	}
+
+	for personType := range GamePersonType(len(gamePersonTypeNames)) {
+		expectedPerson := person
+		assert.NoError(t, expectedPerson.SetType(personType))
+		var migrated GamePerson
+		assert.NoError(t, migrated.UnmarshalBinary(legacyGamePersonFile(t, expectedPerson, 2, uint64(personType))))
+		assert.Equal(t, expectedPerson, migrated)
+	}
+
+	var decoded GamePerson
+	assert.ErrorIs(t, decoded.UnmarshalBinary(legacyGamePersonFile(t, person, 1, 0b110)), ErrCorruptRecord)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(legacyGamePersonFile(t, person, 1, 0b011)), ErrCorruptRecord)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(legacyGamePersonFile(t, person, 2, 7)), ErrInvalidType)
+
+	overflow := legacyGamePersonFile(t, person, 2, 0)
+	overflow[gamePersonHeaderSize+16] = 0xFF
+	overflow[gamePersonHeaderSize+17] = 0x0F
+	assert.ErrorIs(t, decoded.UnmarshalBinary(overflow), ErrOutOfRange)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(overflow), ErrCorruptRecord)
+	assert.Equal(t, GamePerson{}, decoded)
+}
+
+func TestGamePersonBinaryInternedName(t *testing.T) {
+	table := NewNameTable()
+	person, err := NewGamePerson(WithInternedName(table, strings.Repeat("Lancelot ", 10)))
+	assert.NoError(t, err)
+
+	_, err = person.MarshalBinary()
+	assert.ErrorIs(t, err, ErrInternedName)
+	var file bytes.Buffer
+	assert.ErrorIs(t, NewGamePersonWriter(&file).Write(&person), ErrInternedName)
+	assert.Zero(t, file.Len())
+
+	// a record with a table id, as the previous v3 writer produced it
+	data := append(appendGamePersonHeader(nil), make([]byte, gamePersonCodecs[gamePersonVersion].size)...)
+	encodeGamePersonV3(&person, data[gamePersonHeaderSize:])
+	var decoded GamePerson
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data), ErrInternedName)
+	assert.ErrorIs(t, decoded.UnmarshalBinary(data), ErrCorruptRecord)
+	assert.Equal(t, GamePerson{}, decoded)
+}
+
+func TestGamePersonReaderWriter(t *testing.T) {
+	r := rand.New(rand.NewPCG(46, 46))
+	persons := make([]GamePerson, 10000)
+	for i := range persons {
+		var err error
+		persons[i], err = NewGamePerson(randomGamePersonOptions(r)...)
+		assert.NoError(t, err)
+	}
+
+	var file bytes.Buffer
+	writer := NewGamePersonWriter(&file)
+	for i := range persons {
+		assert.NoError(t, writer.Write(&persons[i]))
+	}
+	assert.NoError(t, writer.Flush())
+	assert.Equal(t, gamePersonHeaderSize+64*len(persons), file.Len())
+
+	reader, err := NewGamePersonReader(bytes.NewReader(file.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(gamePersonVersion), reader.Version())
+	for i := range persons {
+		person, err := reader.Read()
+		assert.NoError(t, err)
+		assert.Equal(t, persons[i], person)
+	}
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+
+	reader, err = NewGamePersonReader(bytes.NewReader(file.Bytes()[:gamePersonHeaderSize+100]))
+	assert.NoError(t, err)
+	_, err = reader.Read()
+	assert.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	var empty bytes.Buffer
+	assert.NoError(t, NewGamePersonWriter(&empty).Flush())
+	reader, err = NewGamePersonReader(&empty)
+	assert.NoError(t, err)
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func BenchmarkGamePersonWriter(b *testing.B) {
+	person, _ := NewGamePerson(WithName("Smith"), WithHealth(1000), WithMana(1000))
+	writer := NewGamePersonWriter(io.Discard)
+	b.SetBytes(64)
+	for b.Loop() {
+		_ = writer.Write(&person)
+	}
+	_ = writer.Flush()
+}
+
+func BenchmarkGamePersonReader(b *testing.B) {
+	person, _ := NewGamePerson(WithName("Smith"), WithHealth(1000), WithMana(1000))
+	var file bytes.Buffer
+	writer := NewGamePersonWriter(&file)
+	for range 1 << 16 {
+		_ = writer.Write(&person)
+	}
+	_ = writer.Flush()
+
+	b.SetBytes(64)
+	var reader *GamePersonReader
+	for b.Loop() {
+		if reader == nil {
+			reader, _ = NewGamePersonReader(bytes.NewReader(file.Bytes()))
+		}
+		if _, err := reader.Read(); err != nil {
+			reader = nil
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Setters validate like the matching options and leave the person unchanged on error.
+
+func (p *GamePerson) SetName(name string) error {
+	return p.Apply(WithName(name))
+}
+
+func (p *GamePerson) SetCoordinates(x, y, z int) error {
+	return p.Apply(WithCoordinates(x, y, z))
+}
+
+func (p *GamePerson) SetGold(gold int) error {
+	return p.Apply(WithGold(gold))
+}
+
+// AddGold adds a positive or negative amount, the result must stay in the gold range.
+func (p *GamePerson) AddGold(delta int) error {
+	return p.Apply(WithGold(p.Gold() + delta))
+}
+
+func (p *GamePerson) SetMana(mana int) error {
+	return p.Apply(WithMana(mana))
+}
+
+func (p *GamePerson) SetHealth(health int) error {
+	return p.Apply(WithHealth(health))
+}
+
+func (p *GamePerson) SetRespect(respect int) error {
+	return p.Apply(WithRespect(respect))
+}
+
+func (p *GamePerson) SetStrength(strength int) error {
+	return p.Apply(WithStrength(strength))
+}
+
+func (p *GamePerson) SetExperience(experience int) error {
+	return p.Apply(WithExperience(experience))
+}
+
+func (p *GamePerson) SetLevel(level int) error {
+	return p.Apply(WithLevel(level))
+}
+
+func (p *GamePerson) SetType(personType GamePersonType) error {
+	return p.Apply(WithType(personType))
+}
+
+func (p *GamePerson) toggle(field bitField) {
+	p.storeStats(p.loadStats() ^ 1<<field.offset)
+}
+
+func (p *GamePerson) ToggleHouse() {
+	p.toggle(hasHouseField)
+}
+
+func (p *GamePerson) ToggleGun() {
+	p.toggle(hasGunField)
+}
+
+func (p *GamePerson) ToggleFamily() {
+	p.toggle(hasFamilyField)
+}
+
+func TestGamePersonSetters(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"), WithHealth(1000), WithMana(500), WithRespect(7), WithGun())
+	assert.NoError(t, err)
+
+	assert.NoError(t, person.SetHealth(3))
+	assert.NoError(t, person.SetHealth(12))
+	assert.Equal(t, 12, person.Health())
+	assert.Equal(t, 500, person.Mana())
+
+	assert.NoError(t, person.SetMana(1000))
+	assert.NoError(t, person.SetRespect(1))
+	assert.NoError(t, person.SetStrength(9))
+	assert.NoError(t, person.SetExperience(6))
+	assert.NoError(t, person.SetLevel(2))
+	assert.Equal(t, []int{12, 1000, 1, 9, 6, 2}, []int{
+		person.Health(), person.Mana(), person.Respect(), person.Strength(), person.Experience(), person.Level(),
+	})
+
+	assert.NoError(t, person.SetType(WarriorGamePersonType))
+	assert.NoError(t, person.SetType(BlacksmithGamePersonType))
+	assert.Equal(t, BlacksmithGamePersonType, person.Type())
+
+	assert.NoError(t, person.SetName("Jo"))
+	assert.Equal(t, "Jo", person.Name())
+	assert.Equal(t, make([]byte, 40), person.name[2:])
+
+	assert.NoError(t, person.SetCoordinates(-1, 2, -3))
+	assert.Equal(t, []int{-1, 2, -3}, []int{person.X(), person.Y(), person.Z()})
+
+	assert.NoError(t, person.SetGold(100))
+	assert.NoError(t, person.AddGold(50))
+	assert.NoError(t, person.AddGold(-120))
+	assert.Equal(t, 30, person.Gold())
+
+	person.ToggleGun()
+	assert.False(t, person.HasGun())
+	person.ToggleGun()
+	person.ToggleHouse()
+	assert.True(t, person.HasGun())
+	assert.True(t, person.HasHouse())
+	assert.False(t, person.HasFamily())
+	person.ToggleFamily()
+	assert.True(t, person.HasFamily())
+	assert.Equal(t, 12, person.Health())
+	assert.Equal(t, BlacksmithGamePersonType, person.Type())
+}
+
+func TestGamePersonSettersKeepPersonOnError(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"), WithGold(10), WithHealth(100))
+	assert.NoError(t, err)
+	original := person
+
+	assert.ErrorIs(t, person.SetHealth(1001), ErrOutOfRange)
+	assert.ErrorIs(t, person.AddGold(-11), ErrOutOfRange)
+	assert.ErrorIs(t, person.AddGold(math.MaxUint32), ErrOutOfRange)
+	assert.ErrorIs(t, person.SetType(GamePersonType(len(gamePersonTypeNames))), ErrInvalidType)
+	assert.ErrorIs(t, person.SetName(string(make([]byte, 43))), ErrNameTooLong)
+	assert.ErrorIs(t, person.SetCoordinates(0, math.MaxInt32+1, 0), ErrOutOfRange)
+	assert.Equal(t, original, person)
+}
+
+func TestGamePersonApply(t *testing.T) {
+	person, err := NewGamePerson(WithName("Smith"), WithHealth(100), WithMana(100))
+	assert.NoError(t, err)
+
+	assert.NoError(t, person.Apply(WithHealth(200), WithHealth(300), WithLevel(5), WithHouse()))
+	assert.Equal(t, 300, person.Health())
+	assert.Equal(t, 100, person.Mana())
+	assert.Equal(t, 5, person.Level())
+	assert.True(t, person.HasHouse())
+
+	assert.NoError(t, person.Apply(WithHouse()))
+	assert.True(t, person.HasHouse())
+	assert.False(t, person.HasGun())
+
+	before := person
+	err = person.Apply(WithHealth(1), WithMana(5000), WithLevel(11))
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.EqualError(t, err, "mana 5000 is out of range [0, 1000]\nlevel 11 is out of range [0, 10]")
+	assert.Equal(t, before, person)
+
+	assert.NoError(t, person.Apply())
+	assert.Equal(t, before, person)
+}
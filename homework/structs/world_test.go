@@ -0,0 +1,291 @@
+package main
+
+import (
+	"iter"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Position struct {
+	X, Y, Z int
+}
+
+// World keeps persons in struct-of-arrays layout, so loops over coordinates
+// read 12 bytes per person instead of pulling every 64-byte GamePerson into cache.
+// A person is addressed by the id Add returns.
+type World struct {
+	xs, ys, zs []int32
+	gold       []uint32
+	stats      [][gamePersonStatsSize]byte
+	names      [][gamePersonNameSize]byte
+}
+
+func NewWorld(capacity int) *World {
+	return &World{
+		xs:    make([]int32, 0, capacity),
+		ys:    make([]int32, 0, capacity),
+		zs:    make([]int32, 0, capacity),
+		gold:  make([]uint32, 0, capacity),
+		stats: make([][gamePersonStatsSize]byte, 0, capacity),
+		names: make([][gamePersonNameSize]byte, 0, capacity),
+	}
+}
+
+func (w *World) Len() int {
+	return len(w.xs)
+}
+
+func (w *World) Add(person GamePerson) int {
+	w.xs = append(w.xs, person.x)
+	w.ys = append(w.ys, person.y)
+	w.zs = append(w.zs, person.z)
+	w.gold = append(w.gold, person.gold)
+	w.stats = append(w.stats, person.stats)
+	w.names = append(w.names, person.name)
+	return len(w.xs) - 1
+}
+
+// Spawn creates a person like NewGamePerson and adds it to the world.
+func (w *World) Spawn(options ...Option) (int, error) {
+	person, err := NewGamePerson(options...)
+	if err != nil {
+		return 0, err
+	}
+	return w.Add(person), nil
+}
+
+func (w *World) Person(id int) GamePerson {
+	return GamePerson{
+		x:     w.xs[id],
+		y:     w.ys[id],
+		z:     w.zs[id],
+		gold:  w.gold[id],
+		stats: w.stats[id],
+		name:  w.names[id],
+	}
+}
+
+func (w *World) Set(id int, person GamePerson) {
+	w.xs[id], w.ys[id], w.zs[id] = person.x, person.y, person.z
+	w.gold[id] = person.gold
+	w.stats[id] = person.stats
+	w.names[id] = person.name
+}
+
+func (w *World) Position(id int) Position {
+	return Position{X: int(w.xs[id]), Y: int(w.ys[id]), Z: int(w.zs[id])}
+}
+
+// All yields copies of the persons, use Set to store changes.
+func (w *World) All() iter.Seq2[int, GamePerson] {
+	return func(yield func(int, GamePerson) bool) {
+		for id := range w.xs {
+			if !yield(id, w.Person(id)) {
+				return
+			}
+		}
+	}
+}
+
+func (w *World) Positions() iter.Seq2[int, Position] {
+	return func(yield func(int, Position) bool) {
+		xs, ys, zs := w.xs, w.ys[:len(w.xs)], w.zs[:len(w.xs)]
+		for id, x := range xs {
+			if !yield(id, Position{X: int(x), Y: int(ys[id]), Z: int(zs[id])}) {
+				return
+			}
+		}
+	}
+}
+
+// InBox yields persons with low <= position <= high on every axis, reading only the coordinate columns.
+func (w *World) InBox(low, high Position) iter.Seq2[int, Position] {
+	return func(yield func(int, Position) bool) {
+		xs, ys, zs := w.xs, w.ys[:len(w.xs)], w.zs[:len(w.xs)]
+		for id, x := range xs {
+			if int(x) < low.X || int(x) > high.X {
+				continue
+			}
+			y, z := int(ys[id]), int(zs[id])
+			if y < low.Y || y > high.Y || z < low.Z || z > high.Z {
+				continue
+			}
+			if !yield(id, Position{X: int(x), Y: y, Z: z}) {
+				return
+			}
+		}
+	}
+}
+
+func randomPositionOptions(r *rand.Rand, persons int) []Option {
+	options := randomGamePersonOptions(r)
+	// keep coordinates dense enough for box queries to hit
+	side := max(persons/100, 10)
+	return append(options, WithCoordinates(r.IntN(side), r.IntN(side), r.IntN(side)))
+}
+
+func TestWorld(t *testing.T) {
+	r := rand.New(rand.NewPCG(49, 49))
+	world := NewWorld(0)
+	var persons []GamePerson
+	for range 1000 {
+		person, err := NewGamePerson(randomPositionOptions(r, 1000)...)
+		assert.NoError(t, err)
+		assert.Equal(t, len(persons), world.Add(person))
+		persons = append(persons, person)
+	}
+	assert.Equal(t, len(persons), world.Len())
+
+	for id, person := range world.All() {
+		assert.Equal(t, persons[id], person)
+		assert.Equal(t, Position{X: person.X(), Y: person.Y(), Z: person.Z()}, world.Position(id))
+	}
+
+	count := 0
+	for id, position := range world.Positions() {
+		assert.Equal(t, world.Position(id), position)
+		count++
+		if count == 10 {
+			break
+		}
+	}
+	assert.Equal(t, 10, count)
+
+	person := world.Person(7)
+	assert.NoError(t, person.SetHealth(1))
+	assert.NoError(t, person.SetCoordinates(-5, -6, -7))
+	world.Set(7, person)
+	person = world.Person(7)
+	assert.Equal(t, 1, person.Health())
+	assert.Equal(t, Position{X: -5, Y: -6, Z: -7}, world.Position(7))
+
+	id, err := world.Spawn(WithName("Smith"), WithType(MageGamePersonType))
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, id)
+	person = world.Person(id)
+	assert.Equal(t, MageGamePersonType, person.Type())
+
+	_, err = world.Spawn(WithMana(1001))
+	assert.ErrorIs(t, err, ErrOutOfRange)
+	assert.Equal(t, 1001, world.Len())
+}
+
+func TestWorldInBox(t *testing.T) {
+	r := rand.New(rand.NewPCG(49, 50))
+	world := NewWorld(5000)
+	for range 5000 {
+		_, err := world.Spawn(randomPositionOptions(r, 5000)...)
+		assert.NoError(t, err)
+	}
+
+	boxes := [][2]Position{
+		{{X: 0, Y: 0, Z: 0}, {X: 10, Y: 10, Z: 10}},
+		{{X: 20, Y: 5, Z: 30}, {X: 40, Y: 15, Z: 49}},
+		{{X: 3, Y: 3, Z: 3}, {X: 3, Y: 3, Z: 3}},
+		{{X: 10, Y: 0, Z: 0}, {X: 0, Y: 10, Z: 10}},
+	}
+	for _, box := range boxes {
+		var expected []int
+		for id, person := range world.All() {
+			if person.X() >= box[0].X && person.X() <= box[1].X &&
+				person.Y() >= box[0].Y && person.Y() <= box[1].Y &&
+				person.Z() >= box[0].Z && person.Z() <= box[1].Z {
+				expected = append(expected, id)
+			}
+		}
+		var actual []int
+		for id, position := range world.InBox(box[0], box[1]) {
+			assert.Equal(t, world.Position(id), position)
+			actual = append(actual, id)
+		}
+		assert.Equal(t, expected, actual)
+	}
+
+	for range world.InBox(Position{X: 0, Y: 0, Z: 0}, Position{X: 49, Y: 49, Z: 49}) {
+		break
+	}
+}
+
+const benchmarkPersons = 1 << 18
+
+var benchmarkSink int
+
+func benchmarkWorld(b *testing.B) (*World, []GamePerson) {
+	r := rand.New(rand.NewPCG(1, 2))
+	world := NewWorld(benchmarkPersons)
+	persons := make([]GamePerson, 0, benchmarkPersons)
+	for range benchmarkPersons {
+		person, err := NewGamePerson(randomPositionOptions(r, benchmarkPersons)...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		world.Add(person)
+		persons = append(persons, person)
+	}
+	return world, persons
+}
+
+func BenchmarkSumPositions(b *testing.B) {
+	world, persons := benchmarkWorld(b)
+
+	b.Run("World", func(b *testing.B) {
+		for b.Loop() {
+			sum := 0
+			for _, position := range world.Positions() {
+				sum += position.X + position.Y + position.Z
+			}
+			benchmarkSink = sum
+		}
+	})
+
+	b.Run("WorldColumns", func(b *testing.B) {
+		for b.Loop() {
+			sum := 0
+			xs, ys, zs := world.xs, world.ys[:len(world.xs)], world.zs[:len(world.xs)]
+			for id, x := range xs {
+				sum += int(x) + int(ys[id]) + int(zs[id])
+			}
+			benchmarkSink = sum
+		}
+	})
+
+	b.Run("Slice", func(b *testing.B) {
+		for b.Loop() {
+			sum := 0
+			for i := range persons {
+				sum += persons[i].X() + persons[i].Y() + persons[i].Z()
+			}
+			benchmarkSink = sum
+		}
+	})
+}
+
+func BenchmarkInBox(b *testing.B) {
+	world, persons := benchmarkWorld(b)
+	low, high := Position{X: 100, Y: 100, Z: 100}, Position{X: 1000, Y: 1000, Z: 1000}
+
+	b.Run("World", func(b *testing.B) {
+		for b.Loop() {
+			found := 0
+			for range world.InBox(low, high) {
+				found++
+			}
+			benchmarkSink = found
+		}
+	})
+
+	b.Run("Slice", func(b *testing.B) {
+		for b.Loop() {
+			found := 0
+			for i := range persons {
+				x, y, z := persons[i].X(), persons[i].Y(), persons[i].Z()
+				if x >= low.X && x <= high.X && y >= low.Y && y <= high.Y && z >= low.Z && z <= high.Z {
+					found++
+				}
+			}
+			benchmarkSink = found
+		}
+	})
+}